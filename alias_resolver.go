@@ -0,0 +1,55 @@
+package nodeengine
+
+import (
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// AliasResolver converts the versions given by any version source into
+// version constraints, resolving the aliases that Node.js version managers
+// understand along the way:
+//
+//   - "node", "stable", "current" and "latest" match any version
+//   - "lts" and "lts/*" match the newest LTS release line
+//   - "lts/-N" matches the LTS release line N places before the newest one
+//   - "lts/<codename>" and "lts-<codename>" match the named LTS release line
+type AliasResolver struct {
+	ltsCodenames LTSCodenames
+}
+
+func NewAliasResolver(ltsCodenames LTSCodenames) AliasResolver {
+	return AliasResolver{
+		ltsCodenames: ltsCodenames,
+	}
+}
+
+// ResolveVersion returns the version constraint for the given version or
+// alias. The boolean result is false when the version is neither a known
+// alias nor a valid version constraint.
+func (r AliasResolver) ResolveVersion(version string) (string, bool, error) {
+	version = strings.TrimSpace(strings.ToLower(version))
+
+	switch version {
+	case Node, "stable", "current", "latest":
+		return "*", true, nil
+	case "lts":
+		return r.ltsCodenames.Resolve("*")
+	}
+
+	if codename, ok := strings.CutPrefix(version, "lts/"); ok {
+		return r.ltsCodenames.Resolve(codename)
+	}
+
+	if codename, ok := strings.CutPrefix(version, "lts-"); ok {
+		return r.ltsCodenames.Resolve(codename)
+	}
+
+	version = strings.TrimPrefix(version, "v")
+
+	if _, err := semver.NewConstraint(version); err != nil {
+		return "", false, nil
+	}
+
+	return version, true, nil
+}
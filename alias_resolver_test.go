@@ -0,0 +1,84 @@
+package nodeengine_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testAliasResolver(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path     string
+		resolver nodeengine.AliasResolver
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "buildpack.toml")
+		Expect(os.WriteFile(path, []byte(`
+[metadata.lts-codenames]
+  hydrogen = "18"
+  iron = "20"
+  jod = "22"
+`), 0600)).To(Succeed())
+
+		resolver = nodeengine.NewAliasResolver(nodeengine.NewLTSCodenames(path))
+	})
+
+	it("resolves versions and aliases to a version constraint", func() {
+		testCases := map[string]string{
+			"20.11.1":      "20.11.1",
+			"v20":          "20",
+			"~20.11":       "~20.11",
+			">=18 <21":     ">=18 <21",
+			" 20.x ":       "20.x",
+			"node":         "*",
+			"stable":       "*",
+			"current":      "*",
+			"latest":       "*",
+			"lts":          "22.*",
+			"LTS":          "22.*",
+			"lts/*":        "22.*",
+			"lts/-1":       "20.*",
+			"lts/iron":     "20.*",
+			"lts/Hydrogen": "18.*",
+			"lts-jod":      "22.*",
+			"v23.0.0-rc.1": "23.0.0-rc.1",
+		}
+
+		for input, output := range testCases {
+			constraint, ok, err := resolver.ResolveVersion(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue(), fmt.Sprintf("input of %q was not resolved", input))
+			Expect(constraint).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
+		}
+	})
+
+	it("does not resolve invalid versions and unknown aliases", func() {
+		for _, input := range []string{"", "not-a-version", "lts/unknown", "lts/-3", "iojs"} {
+			_, ok, err := resolver.ResolveVersion(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse(), fmt.Sprintf("input of %q was resolved", input))
+		}
+	})
+
+	context("failure cases", func() {
+		context("when the LTS codenames cannot be read", func() {
+			it.Before(func() {
+				Expect(os.Remove(path)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, _, err := resolver.ResolveVersion("lts")
+				Expect(err).To(MatchError(ContainSubstring("failed to read LTS codenames")))
+			})
+		})
+	})
+}
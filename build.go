@@ -1,16 +1,19 @@
 package nodeengine
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/paketo-buildpacks/libnodejs"
+	"github.com/Masterminds/semver/v3"
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/cargo"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
 	"github.com/paketo-buildpacks/packit/v2/postal"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
@@ -34,6 +37,25 @@ type SBOMGenerator interface {
 	GenerateFromDependency(dependency postal.Dependency, dir string) (sbom.SBOM, error)
 }
 
+// versionSourcePriorities lists the version sources of node build plan
+// entries from highest to lowest priority. It extends the ordering used by
+// libnodejs with the sources that only this buildpack detects. A Volta pin is
+// what developers run locally, so it is preferred over the engines range in
+// the same package.json. The deprecated buildpack.yml keeps the priority it
+// had before it was deprecated.
+var versionSourcePriorities = []interface{}{
+	"BP_NODE_VERSION",
+	BuildpackYMLSource,
+	VoltaSource,
+	PackageJSONSource,
+	DevEnginesSource,
+	NvmrcSource,
+	NodeVersionSource,
+	ToolVersionsSource,
+	DotMiseSource,
+	MiseSource,
+}
+
 func IsLayerReusable(nodeLayer packit.Layer, depChecksum string, build bool, launch bool, logger scribe.Emitter) bool {
 	logger.Debug.Process("Checking if layer %s can be reused", nodeLayer.Path)
 
@@ -61,6 +83,8 @@ func Build(entryResolver EntryResolver, dependencyManager DependencyManager, sbo
 
 		var buildMetadata = packit.BuildMetadata{}
 		var launchMetadata = packit.LaunchMetadata{}
+		// layers holds the layers contributed next to the node layer.
+		var layers []packit.Layer
 		nodeLayer, err := context.Layers.Get(Node)
 		if err != nil {
 			return packit.BuildResult{}, err
@@ -68,7 +92,7 @@ func Build(entryResolver EntryResolver, dependencyManager DependencyManager, sbo
 
 		logger.Process("Resolving Node Engine version")
 
-		entry, allEntries := libnodejs.ResolveNodeVersion(entryResolver.Resolve, context.Plan)
+		entry, allEntries := entryResolver.Resolve(Node, context.Plan.Entries, versionSourcePriorities)
 		if entry.Name == "" && len(allEntries) == 0 {
 			logger.Process("Node no longer requested by plan, satisfied by extension")
 
@@ -85,44 +109,349 @@ func Build(entryResolver EntryResolver, dependencyManager DependencyManager, sbo
 			}
 
 			nodeLayer.SharedEnv.Default("NODE_HOME", "")
+
+			if corepackRequested(context.Plan.Entries) {
+				logger.Subprocess("Not enabling corepack, since Node is not installed by this buildpack")
+			}
+
+			if len(npmEntries(context.Plan.Entries)) > 0 {
+				logger.Subprocess("Not installing the requested npm version, since Node is not installed by this buildpack")
+			}
 		} else {
 			logger.Candidates(allEntries)
 
-			version, _ := entry.Metadata["version"].(string)
-			dependency, err := dependencyManager.Resolve(filepath.Join(context.CNBPath, "buildpack.toml"), entry.Name, version, context.Stack)
+			for _, e := range allEntries {
+				if source, _ := e.Metadata["version-source"].(string); source == BuildpackYMLSource {
+					version, _ := e.Metadata["version"].(string)
+					warnBuildpackYMLDeprecation(logger, version)
+				}
+			}
+
+			dependencies, err := availableDependencies(filepath.Join(context.CNBPath, "buildpack.toml"), context.Stack)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			resolution := os.Getenv("BP_NODE_VERSION_RESOLUTION")
+
+			distribution, err := distributionFromEnvironment(filepath.Join(context.CNBPath, ReleaseKeysFile))
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			var (
+				dependency postal.Dependency
+				fallback   VersionFallback
+				pin        VersionPin
+			)
+
+			if distribution.URI != "" {
+				logger.Subprocess("Installing the distribution at %s, version requirements are ignored", distribution.URI)
+				logger.Break()
+
+				dependency = distribution
+				entry = packit.BuildpackPlanEntry{
+					Name: Node,
+					Metadata: map[string]interface{}{
+						"version":        distribution.Version,
+						"version-source": DistributionSource,
+					},
+				}
+			} else {
+				mirror, err := distMirrorFromEnvironment()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				// The alternatives of asdf and mise versions are narrowed to the
+				// first available one before they are intersected, like the
+				// entry selected by priority below.
+				var candidates []packit.BuildpackPlanEntry
+				for _, candidate := range allEntries {
+					candidates = append(candidates, firstAvailableToolVersion(candidate, dependencies))
+				}
+
+				// When no dependency satisfies every requirement, the
+				// intersection is looked up in the BP_NODE_DIST_MIRROR, and only
+				// reported when the mirror cannot satisfy it either.
+				var intersectionErr IntersectionError
+
+				switch resolution {
+				case "", ResolutionPriority:
+					// Each workspace is resolved by priority on its own, and the
+					// version installed has to satisfy all of them.
+					if workspaces := planWorkspaces(allEntries); len(workspaces) > 1 {
+						logger.Subprocess("Intersecting the version requirements of workspaces %s", strings.Join(workspaces, ", "))
+						intersection, err := intersectWorkspaces(candidates, dependencies)
+						if errors.As(err, &intersectionErr) && mirror != "" {
+							intersection, err = intersectionErr.Entry(), nil
+						}
+						if err != nil {
+							return packit.BuildResult{}, err
+						}
+
+						if intersection.Name != "" {
+							entry = intersection
+						}
+					}
+				case ResolutionIntersect:
+					logger.Subprocess("Intersecting the version requirements of all candidate version sources")
+					intersection, err := intersectVersions(candidates, dependencies)
+					if errors.As(err, &intersectionErr) && mirror != "" {
+						intersection, err = intersectionErr.Entry(), nil
+					}
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+
+					if intersection.Name != "" {
+						entry = intersection
+					}
+				default:
+					return packit.BuildResult{}, fmt.Errorf("invalid value for BP_NODE_VERSION_RESOLUTION: %q, expected %q or %q", resolution, ResolutionPriority, ResolutionIntersect)
+				}
+
+				entry = firstAvailableToolVersion(entry, dependencies)
+				entry = tolerateDevEnginesRuntime(entry, allEntries, dependencies, logger)
+
+				allowPrerelease, err := prereleaseAllowed()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				err = checkPrerelease(entry, allowPrerelease)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				policy, err := fallbackPolicy(os.Getenv("BP_NODE_VERSION_FALLBACK"))
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				unsatisfiedErr := checkSatisfiable(entry, dependencies)
+				if unsatisfiedErr != nil && mirror != "" {
+					keys, err := NewReleaseKeys(filepath.Join(context.CNBPath, ReleaseKeysFile))
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+
+					mirrored, ok, err := resolveFromMirror(mirror, entry, dependencies, keys)
+					if err != nil {
+						return packit.BuildResult{}, fmt.Errorf("failed to resolve Node version from BP_NODE_DIST_MIRROR: %w", err)
+					}
+
+					if ok {
+						version, _ := entry.Metadata["version"].(string)
+						logger.Subprocess("No version in the buildpack.toml satisfies %q, resolved %s from BP_NODE_DIST_MIRROR", version, mirrored.Version)
+						logger.Break()
+
+						dependency, unsatisfiedErr = mirrored, nil
+					}
+				}
+
+				if unsatisfiedErr != nil && len(intersectionErr.Requirements) > 0 {
+					return packit.BuildResult{}, intersectionErr
+				}
+
+				if unsatisfiedErr != nil {
+					entry, fallback, err = applyFallback(entry, dependencies, policy, unsatisfiedErr)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+
+				pin, err = versionPinFromEnvironment()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if dependency.URI == "" {
+					entry = pin.Apply(entry, nodeLayer.Metadata, dependencies, clock.Now(), logger)
+
+					version, _ := entry.Metadata["version"].(string)
+					dependency, err = dependencyManager.Resolve(filepath.Join(context.CNBPath, "buildpack.toml"), entry.Name, version, context.Stack)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+
+				if fallback.Policy != "" {
+					fallback.Resolved = dependency.Version
+
+					logger.Process("WARNING: Node version %q requested by %s is not available.", fallback.Requested, fallback.Source)
+					logger.Subprocess("Installing %s instead, as allowed by BP_NODE_VERSION_FALLBACK=%s (%q)", dependency.Version, fallback.Policy, fallback.Fallback)
+					logger.Break()
+				}
+
+			}
+
+			eolPolicy, err := eolPolicyFromEnvironment()
 			if err != nil {
 				return packit.BuildResult{}, err
 			}
 
-			logger.SelectedDependency(entry, dependency, clock.Now())
+			logger.SelectedDependency(entry, eolPolicy.Reported(dependency), clock.Now())
+
+			err = eolPolicy.Check(dependency, clock.Now(), logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			security, err := securityPolicy()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			releases, err := securityReleases(filepath.Join(context.CNBPath, "buildpack.toml"), dependencies)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			// A custom distribution may carry its own fixes, so it is not
+			// compared against the security releases of the buildpack.
+			if distribution.URI == "" {
+				err = checkSecurityReleases(dependency, releases, security, planEntryLabel(entry), logger)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+
+			trace := newDecisionTrace(resolution, entry, allEntries, dependencies, dependency, clock.Now())
+			trace.Log(logger)
+
+			// The version requirements of the application, devEngines.runtime
+			// included, do not apply to a custom distribution.
+			if distribution.URI == "" {
+				err = checkDevEnginesRuntime(allEntries, dependency, logger)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
 
 			sbomDisabled, err := checkSbomDisabled()
 			if err != nil {
 				return packit.BuildResult{}, err
 			}
 
-			var legacySBOM []packit.BOMEntry
-			if !sbomDisabled {
-				legacySBOM = dependencyManager.GenerateBillOfMaterials(dependency)
+			var npmDependency postal.Dependency
+			pinnedNpm := npmEntries(context.Plan.Entries)
+			if len(pinnedNpm) > 0 {
+				npmEntry, _ := entryResolver.Resolve(Npm, pinnedNpm, npmVersionSourcePriorities)
+
+				version, _ := npmEntry.Metadata["version"].(string)
+				npmDependency, err = dependencyManager.Resolve(filepath.Join(context.CNBPath, "buildpack.toml"), Npm, version, context.Stack)
+				switch {
+				case err == nil:
+					logger.SelectedDependency(npmEntry, npmDependency, clock.Now())
+				case unavailableNpm(npmEntry, logger):
+					pinnedNpm = nil
+				default:
+					return packit.BuildResult{}, fmt.Errorf("failed to resolve npm version %q requested by %s: %w", version, planEntryLabel(npmEntry), err)
+				}
 			}
 
 			launch, build := entryResolver.MergeLayerTypes("node", context.Plan.Entries)
 
+			// The corepack shims run the Node of the node layer, so the layer
+			// has to be available during the build when corepack is enabled.
+			if corepackRequested(context.Plan.Entries) {
+				build = true
+			}
+
+			slim, err := runtimeSlim(context.Plan.Entries)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			// With a slim runtime, the application is launched from the
+			// node-runtime layer and the full installation is only cached for
+			// later builds.
+			slimLaunch := slim && launch
+
+			var legacySBOM, launchSBOM []packit.BOMEntry
+			if !sbomDisabled {
+				if len(pinnedNpm) > 0 {
+					legacySBOM = dependencyManager.GenerateBillOfMaterials(dependency, npmDependency)
+				} else {
+					legacySBOM = dependencyManager.GenerateBillOfMaterials(dependency)
+				}
+				launchSBOM = legacySBOM
+
+				// The npm layer is not a launch layer with a slim runtime, so npm
+				// is left out of the launch image.
+				if len(pinnedNpm) > 0 && slimLaunch {
+					launchSBOM = dependencyManager.GenerateBillOfMaterials(dependency)
+				}
+			}
+
 			if build {
 				buildMetadata = packit.BuildMetadata{BOM: legacySBOM}
 			}
 
 			if launch {
-				launchMetadata = packit.LaunchMetadata{BOM: legacySBOM}
+				launchMetadata = packit.LaunchMetadata{BOM: launchSBOM}
 			}
 
-			if IsLayerReusable(nodeLayer, dependency.Checksum, build, launch, logger) {
+			// The slim runtime is copied out of the node layer, so it can only be
+			// reused when its contents were cached by the previous build.
+			reusable := IsLayerReusable(nodeLayer, dependency.Checksum, build, launch, logger)
+			if reusable && slimLaunch {
+				reusable, err = fs.Exists(filepath.Join(nodeLayer.Path, "bin", "node"))
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+
+			if reusable {
 				logger.Process("Reusing cached layer %s", nodeLayer.Path)
 				logger.Break()
 
 				nodeLayer.Launch, nodeLayer.Build, nodeLayer.Cache = launch, build, build
+				delete(nodeLayer.Metadata, RuntimeSlimKey)
+				if slimLaunch {
+					nodeLayer.Launch, nodeLayer.Cache = false, true
+					nodeLayer.Metadata[RuntimeSlimKey] = true
+				}
+				nodeLayer.Metadata[DecisionTraceKey] = trace
+				delete(nodeLayer.Metadata, FallbackKey)
+				if fallback.Policy != "" {
+					nodeLayer.Metadata[FallbackKey] = fallback
+				}
+				delete(nodeLayer.Metadata, PinnedVersionKey)
+				if pin.Enabled {
+					nodeLayer.Metadata[PinnedVersionKey] = dependency.Version
+				}
+
+				if slimLaunch {
+					runtimeLayer, err := installRuntime(context, nodeLayer, dependency, sbomGenerator, sbomDisabled, logger, clock)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+					layers = append(layers, runtimeLayer)
+				}
+
+				if len(pinnedNpm) > 0 {
+					npmLayer, err := installNpm(context, npmDependency, dependencyManager, sbomGenerator, build, launch && !slimLaunch, sbomDisabled, logger, clock)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+					layers = append(layers, npmLayer)
+				}
+
+				if corepackRequested(context.Plan.Entries) {
+					corepackLayer, enabled, err := enableCorepack(context.Layers, nodeLayer.Path, dependency.Version, logger)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+
+					if enabled {
+						layers = append(layers, corepackLayer)
+					}
+				}
+
 				return packit.BuildResult{
-					Layers: []packit.Layer{nodeLayer},
+					Layers: append([]packit.Layer{nodeLayer}, layers...),
 					Build:  buildMetadata,
 					Launch: launchMetadata,
 				}, nil
@@ -136,18 +465,42 @@ func Build(entryResolver EntryResolver, dependencyManager DependencyManager, sbo
 			}
 
 			nodeLayer.Launch, nodeLayer.Build, nodeLayer.Cache = launch, build, build
+			if slimLaunch {
+				nodeLayer.Launch, nodeLayer.Cache = false, true
+			}
 
 			nodeLayer.Metadata = map[string]interface{}{
-				DepKey:    dependency.Checksum,
-				BuildKey:  build,
-				LaunchKey: launch,
+				DepKey:           dependency.Checksum,
+				BuildKey:         build,
+				LaunchKey:        launch,
+				DecisionTraceKey: trace,
+			}
+
+			if slimLaunch {
+				nodeLayer.Metadata[RuntimeSlimKey] = true
+			}
+
+			if fallback.Policy != "" {
+				nodeLayer.Metadata[FallbackKey] = fallback
+			}
+
+			if pin.Enabled {
+				nodeLayer.Metadata[PinnedVersionKey] = dependency.Version
 			}
 
 			logger.Subprocess("Installing Node Engine %s", dependency.Version)
 			duration, err := clock.Measure(func() error {
-				return dependencyManager.Deliver(dependency, context.CNBPath, nodeLayer.Path, context.Platform.Path)
+				err := dependencyManager.Deliver(dependency, context.CNBPath, nodeLayer.Path, context.Platform.Path)
+				if err != nil || distribution.URI == "" {
+					return err
+				}
+
+				return stripDistributionRoot(nodeLayer.Path)
 			})
 			if err != nil {
+				if distribution.URI != "" {
+					return packit.BuildResult{}, fmt.Errorf("failed to install Node from BP_NODE_DISTRIBUTION_URL %s: %w", distribution.URI, err)
+				}
 				return packit.BuildResult{}, err
 			}
 
@@ -178,6 +531,33 @@ func Build(entryResolver EntryResolver, dependencyManager DependencyManager, sbo
 				}
 			}
 			nodeLayer.SharedEnv.Default("NODE_HOME", nodeLayer.Path)
+
+			if slimLaunch {
+				runtimeLayer, err := installRuntime(context, nodeLayer, dependency, sbomGenerator, sbomDisabled, logger, clock)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				layers = append(layers, runtimeLayer)
+			}
+
+			if len(pinnedNpm) > 0 {
+				npmLayer, err := installNpm(context, npmDependency, dependencyManager, sbomGenerator, build, launch && !slimLaunch, sbomDisabled, logger, clock)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				layers = append(layers, npmLayer)
+			}
+
+			if corepackRequested(context.Plan.Entries) {
+				corepackLayer, enabled, err := enableCorepack(context.Layers, nodeLayer.Path, dependency.Version, logger)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if enabled {
+					layers = append(layers, corepackLayer)
+				}
+			}
 		}
 
 		var optimizedMemory bool
@@ -209,7 +589,7 @@ func Build(entryResolver EntryResolver, dependencyManager DependencyManager, sbo
 		logger.Break()
 
 		return packit.BuildResult{
-			Layers: []packit.Layer{nodeLayer},
+			Layers: append([]packit.Layer{nodeLayer}, layers...),
 			Build:  buildMetadata,
 			Launch: launchMetadata,
 		}, nil
@@ -226,3 +606,80 @@ func checkSbomDisabled() (bool, error) {
 	}
 	return false, nil
 }
+
+// tolerateDevEnginesRuntime replaces the selected entry when it is a
+// devEngines.runtime requirement that none of the dependencies satisfies and
+// whose onFail setting tolerates a mismatch. The next entry in priority order
+// is used instead, or the default version when there is none, and the
+// mismatch is left for checkDevEnginesRuntime to report.
+func tolerateDevEnginesRuntime(entry packit.BuildpackPlanEntry, entries []packit.BuildpackPlanEntry, dependencies []postal.Dependency, logger scribe.Emitter) packit.BuildpackPlanEntry {
+	onFail, _ := entry.Metadata["on-fail"].(string)
+	if entry.Metadata["version-source"] != DevEnginesSource || (onFail != OnFailIgnore && onFail != OnFailWarn) {
+		return entry
+	}
+
+	if checkSatisfiable(entry, dependencies) == nil {
+		return entry
+	}
+
+	next := packit.BuildpackPlanEntry{
+		Name: Node,
+		Metadata: map[string]interface{}{
+			"version":        "default",
+			"version-source": "default",
+		},
+	}
+
+	for _, candidate := range entries {
+		if candidate.Metadata["version-source"] != DevEnginesSource {
+			next = candidate
+			break
+		}
+	}
+
+	version, _ := entry.Metadata["version"].(string)
+	logger.Debug.Subprocess("No version satisfies devEngines.runtime version %q, using %s instead (onFail: %s)", version, planEntryLabel(next), onFail)
+
+	return next
+}
+
+// checkDevEnginesRuntime compares the selected dependency against the
+// devEngines.runtime requirement of the plan, if there is one, and applies
+// its onFail behavior when the dependency does not satisfy the requirement.
+// The buildpack can only install what it resolved, so "download" is treated
+// like "error".
+func checkDevEnginesRuntime(entries []packit.BuildpackPlanEntry, dependency postal.Dependency, logger scribe.Emitter) error {
+	for _, entry := range entries {
+		if source, _ := entry.Metadata["version-source"].(string); source != DevEnginesSource {
+			continue
+		}
+
+		version, _ := entry.Metadata["version"].(string)
+		constraint, err := semver.NewConstraint(version)
+		if err != nil {
+			return fmt.Errorf("invalid devEngines.runtime version constraint %q: %w", version, err)
+		}
+
+		selected, err := semver.NewVersion(dependency.Version)
+		if err != nil {
+			return err
+		}
+
+		if constraint.Check(selected) {
+			continue
+		}
+
+		onFail, _ := entry.Metadata["on-fail"].(string)
+		switch onFail {
+		case OnFailIgnore:
+			logger.Debug.Subprocess("Ignoring that %s %s does not satisfy devEngines.runtime version %q", dependency.Name, dependency.Version, version)
+		case OnFailWarn:
+			logger.Action("WARNING: %s %s does not satisfy devEngines.runtime version %q from package.json.", dependency.Name, dependency.Version, version)
+			logger.Break()
+		default:
+			return fmt.Errorf("selected %s version %s does not satisfy devEngines.runtime version %q from package.json (onFail: %s)", dependency.Name, dependency.Version, version, onFail)
+		}
+	}
+
+	return nil
+}
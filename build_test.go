@@ -3,16 +3,22 @@ package nodeengine_test
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
 	"github.com/paketo-buildpacks/node-engine/v5/fakes"
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/draft"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 
 	//nolint Ignore SA1019, informed usage of deprecated package
@@ -51,6 +57,18 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		workingDir, err = os.MkdirTemp("", "working-dir")
 		Expect(err).NotTo(HaveOccurred())
 
+		Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "10.11.12"
+  stacks = ["some-stack"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "12.13.14"
+  stacks = ["some-stack"]
+`), 0600)).To(Succeed())
+
 		entryResolver = &fakes.EntryResolver{}
 		entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
 			Name: "node",
@@ -59,6 +77,22 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				"version-source": "BP_NODE_VERSION",
 			},
 		}
+		entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+			{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "~10",
+					"version-source": "BP_NODE_VERSION",
+				},
+			},
+			{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "*",
+					"version-source": ".nvmrc",
+				},
+			},
+		}
 		entryResolver.MergeLayerTypesCall.Returns.Launch = false
 		entryResolver.MergeLayerTypesCall.Returns.Build = false
 
@@ -142,6 +176,29 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			nodeengine.DepKey:    "",
 			nodeengine.BuildKey:  false,
 			nodeengine.LaunchKey: false,
+			nodeengine.DecisionTraceKey: nodeengine.DecisionTrace{
+				Resolution: "priority",
+				Candidates: []nodeengine.DecisionCandidate{
+					{
+						Source:   "BP_NODE_VERSION",
+						Version:  "~10",
+						Priority: 1,
+						Matches:  []string{"10.11.12"},
+						Selected: true,
+					},
+					{
+						Source:   ".nvmrc",
+						Version:  "*",
+						Priority: 6,
+						Matches:  []string{"12.13.14", "10.11.12"},
+					},
+				},
+				Selected: nodeengine.DecisionSelection{
+					Source:   "BP_NODE_VERSION",
+					Version:  "~10",
+					Resolved: "10.11.12",
+				},
+			},
 		}))
 
 		Expect(layer.SBOM.Formats()).To(HaveLen(2))
@@ -224,6 +281,19 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				},
 			},
 		}))
+		Expect(entryResolver.ResolveCall.Receives.Name).To(Equal("node"))
+		Expect(entryResolver.ResolveCall.Receives.Priorities).To(Equal([]interface{}{
+			"BP_NODE_VERSION",
+			"buildpack.yml",
+			"volta",
+			"package.json",
+			"devEngines",
+			".nvmrc",
+			".node-version",
+			".tool-versions",
+			".mise.toml",
+			"mise.toml",
+		}))
 		Expect(entryResolver.MergeLayerTypesCall.Receives.Name).To(Equal("node"))
 		Expect(entryResolver.MergeLayerTypesCall.Receives.Entries).To(Equal(
 			[]packit.BuildpackPlanEntry{
@@ -310,6 +380,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			entryResolver.MergeLayerTypesCall.Returns.Launch = true
 			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			t.Setenv("BP_NODE_RUNTIME_SLIM", "false")
 		})
 
 		it.After(func() {
@@ -363,6 +435,186 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when the application is launched with the slim runtime", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			dependencyManager.ResolveCall.Returns.Dependency = postal.Dependency{
+				Name:     "Node Engine",
+				Version:  "10.11.12",
+				Checksum: "sha256:node-sha",
+			}
+			dependencyManager.DeliverCall.Stub = func(_ postal.Dependency, _, layerPath string, _ string) error {
+				Expect(os.MkdirAll(filepath.Join(layerPath, "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layerPath, "lib", "node_modules", "npm"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layerPath, "include", "node"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layerPath, "LICENSE"), []byte("license"), 0644)).To(Succeed())
+				return os.WriteFile(filepath.Join(layerPath, "bin", "node"), []byte("node"), 0755)
+			}
+		})
+
+		it("launches from a runtime layer that only holds the node binary", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(2))
+
+			nodeLayer := result.Layers[0]
+			Expect(nodeLayer.Name).To(Equal("node"))
+			Expect(nodeLayer.Build).To(BeTrue())
+			Expect(nodeLayer.Cache).To(BeTrue())
+			Expect(nodeLayer.Launch).To(BeFalse())
+			Expect(nodeLayer.Metadata).To(HaveKeyWithValue(nodeengine.RuntimeSlimKey, true))
+
+			runtimeLayer := result.Layers[1]
+			Expect(runtimeLayer.Name).To(Equal("node-runtime"))
+			Expect(runtimeLayer.Build).To(BeFalse())
+			Expect(runtimeLayer.Cache).To(BeFalse())
+			Expect(runtimeLayer.Launch).To(BeTrue())
+			Expect(runtimeLayer.Metadata).To(HaveKeyWithValue(nodeengine.DepKey, "sha256:node-sha"))
+			Expect(runtimeLayer.Metadata).To(HaveKeyWithValue(nodeengine.BuildKey, false))
+			Expect(runtimeLayer.Metadata).To(HaveKeyWithValue(nodeengine.LaunchKey, true))
+			Expect(runtimeLayer.Metadata).To(HaveKeyWithValue(nodeengine.DecisionTraceKey, nodeLayer.Metadata[nodeengine.DecisionTraceKey]))
+			Expect(runtimeLayer.Metadata).NotTo(HaveKey(nodeengine.PinnedVersionKey))
+			Expect(runtimeLayer.LaunchEnv).To(Equal(packit.Environment{
+				"NODE_HOME.default":    filepath.Join(layersDir, "node-runtime"),
+				"NODE_ENV.default":     "production",
+				"NODE_VERBOSE.default": "false",
+				"NODE_OPTIONS.default": "--use-openssl-ca",
+			}))
+			Expect(runtimeLayer.ExecD).To(Equal([]string{
+				filepath.Join(cnbDir, "bin", "optimize-memory"),
+				filepath.Join(cnbDir, "bin", "inspector"),
+			}))
+			Expect(runtimeLayer.SBOM.Formats()).To(HaveLen(2))
+
+			Expect(filepath.Join(layersDir, "node-runtime", "bin", "node")).To(BeARegularFile())
+			Expect(filepath.Join(layersDir, "node-runtime", "LICENSE")).To(BeARegularFile())
+			Expect(filepath.Join(layersDir, "node-runtime", "lib")).NotTo(BeADirectory())
+			Expect(filepath.Join(layersDir, "node-runtime", "include")).NotTo(BeADirectory())
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Copying the Node runtime into %s", filepath.Join(layersDir, "node-runtime"))))
+		})
+
+		context("when the node and runtime layers are cached", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "node.toml"), []byte("[metadata]\ndependency-sha = \"sha256:node-sha\"\nbuild = true\nlaunch = true\nruntime-slim = true\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, "node-runtime.toml"), []byte("[metadata]\ndependency-sha = \"sha256:node-sha\"\nbuild = false\nlaunch = true\n"), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, "node", "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, "node", "bin", "node"), []byte("node"), 0755)).To(Succeed())
+			})
+
+			it("reuses both layers", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+				Expect(result.Layers).To(HaveLen(2))
+				Expect(result.Layers[0].Launch).To(BeFalse())
+				Expect(result.Layers[1].Name).To(Equal("node-runtime"))
+				Expect(result.Layers[1].Launch).To(BeTrue())
+				Expect(result.Layers[1].LaunchEnv).To(Equal(packit.Environment{
+					"NODE_HOME.default":    filepath.Join(layersDir, "node-runtime"),
+					"NODE_ENV.default":     "production",
+					"NODE_VERBOSE.default": "false",
+					"NODE_OPTIONS.default": "--use-openssl-ca",
+				}))
+				Expect(result.Layers[1].ExecD).To(Equal([]string{
+					filepath.Join(cnbDir, "bin", "optimize-memory"),
+					filepath.Join(cnbDir, "bin", "inspector"),
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Reusing cached layer %s", filepath.Join(layersDir, "node-runtime"))))
+			})
+
+			context("when BP_NODE_PIN_RESOLVED is set", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_PIN_RESOLVED", "true")
+				})
+
+				it("records the selection on the runtime layer", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Layers[1].Metadata).To(HaveKeyWithValue(nodeengine.PinnedVersionKey, "10.11.12"))
+					Expect(result.Layers[1].Metadata).To(HaveKeyWithValue(nodeengine.DecisionTraceKey, HaveField("Selected.Source", "BP_NODE_VERSION")))
+				})
+			})
+		})
+
+		context("when the node layer was cached without the slim runtime", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "node.toml"), []byte("[metadata]\ndependency-sha = \"sha256:node-sha\"\nbuild = true\nlaunch = true\n"), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, "node", "bin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layersDir, "node", "bin", "node"), []byte("node"), 0755)).To(Succeed())
+			})
+
+			it("reuses node and only changes the layer flags", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+				Expect(result.Layers[0].Launch).To(BeFalse())
+				Expect(result.Layers[0].Cache).To(BeTrue())
+				Expect(result.Layers[0].Metadata).To(HaveKeyWithValue(nodeengine.RuntimeSlimKey, true))
+				Expect(filepath.Join(layersDir, "node-runtime", "bin", "node")).To(BeARegularFile())
+			})
+
+			context("when its contents were not cached", func() {
+				it.Before(func() {
+					Expect(os.RemoveAll(filepath.Join(layersDir, "node", "bin"))).To(Succeed())
+				})
+
+				it("reinstalls node", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.DeliverCall.CallCount).To(Equal(1))
+				})
+			})
+		})
+
+		context("when a plan entry requires npm", func() {
+			it.Before(func() {
+				buildContext.Plan.Entries = append(buildContext.Plan.Entries, packit.BuildpackPlanEntry{Name: "npm"})
+			})
+
+			it("launches from the node layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(1))
+				Expect(result.Layers[0].Launch).To(BeTrue())
+			})
+
+			context("when BP_NODE_RUNTIME_SLIM is true", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_RUNTIME_SLIM", "true")
+				})
+
+				it("launches from the runtime layer", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Layers).To(HaveLen(2))
+					Expect(result.Layers[1].Name).To(Equal("node-runtime"))
+				})
+			})
+		})
+
+		context("when BP_NODE_RUNTIME_SLIM is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_RUNTIME_SLIM", "maybe")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_RUNTIME_SLIM value maybe")))
+			})
+		})
+	})
+
 	context("when there is a dependency cache match", func() {
 		it.Before(func() {
 			err := os.WriteFile(filepath.Join(layersDir, "node.toml"), []byte("[metadata]\ndependency-sha = \"some-sha\"\nbuild = false\nlaunch = true\n"), 0600)
@@ -374,6 +626,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			}
 			entryResolver.MergeLayerTypesCall.Returns.Launch = true
 			entryResolver.MergeLayerTypesCall.Returns.Build = false
+
+			t.Setenv("BP_NODE_RUNTIME_SLIM", "false")
 		})
 
 		it("exits build process early", func() {
@@ -412,6 +666,8 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(buffer.String()).To(ContainSubstring("Selected Node Engine version (using BP_NODE_VERSION): "))
 			Expect(buffer.String()).To(ContainSubstring("Reusing cached layer"))
 			Expect(buffer.String()).ToNot(ContainSubstring("Executing build process"))
+
+			Expect(result.Layers[0].Metadata).To(HaveKeyWithValue(nodeengine.DecisionTraceKey, HaveField("Selected.Source", "BP_NODE_VERSION")))
 		})
 
 		it("the cached layer is NOT used if build requirements do not match", func() {
@@ -470,11 +726,1987 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 	})
 
+	context("when the log level is DEBUG", func() {
+		it.Before(func() {
+			dependencyManager.ResolveCall.Returns.Dependency = postal.Dependency{
+				Name:            "Node Engine",
+				Version:         "10.11.12",
+				DeprecationDate: time.Date(2020, time.April, 30, 0, 0, 0, 0, time.UTC),
+			}
+
+			build = nodeengine.Build(entryResolver, dependencyManager, sbomGenerator, scribe.NewEmitter(buffer).WithLevel("DEBUG"), chronos.DefaultClock)
+		})
+
+		it("prints the version selection decision trace", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("Version selection (resolution: priority)"))
+			Expect(buffer.String()).To(ContainSubstring(`BP_NODE_VERSION (priority 1): "~10" matches 10.11.12 [selected]`))
+			Expect(buffer.String()).To(ContainSubstring(`.nvmrc (priority 6): "*" matches 12.13.14, 10.11.12`))
+			Expect(buffer.String()).To(ContainSubstring(`Selected 10.11.12 from "~10" (deprecated since 2020-04-30T00:00:00Z)`))
+
+			Expect(result.Layers[0].Metadata[nodeengine.DecisionTraceKey]).To(HaveField("Selected", nodeengine.DecisionSelection{
+				Source:          "BP_NODE_VERSION",
+				Version:         "~10",
+				Resolved:        "10.11.12",
+				Deprecated:      true,
+				DeprecationDate: "2020-04-30T00:00:00Z",
+			}))
+		})
+	})
+
+	context("when the plan contains a buildpack.yml requirement", func() {
+		it.Before(func() {
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "~10",
+						"version-source": "buildpack.yml",
+					},
+				},
+			}
+		})
+
+		it("warns that buildpack.yml is deprecated", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("WARNING: Setting the Node version through buildpack.yml is deprecated and will be removed in a future release."))
+			Expect(buffer.String()).To(ContainSubstring(`BP_NODE_VERSION="~10"`))
+		})
+	})
+
+	context("when the volta configuration only pins yarn", func() {
+		it.Before(func() {
+			buildContext.Plan.Entries = []packit.BuildpackPlanEntry{
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "10.*.*",
+						"version-source": ".nvmrc",
+						"yarn":           "1.22.19",
+					},
+				},
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "12.x",
+						"version-source": "package.json",
+						"yarn":           "1.22.19",
+					},
+				},
+			}
+
+			build = nodeengine.Build(draft.NewPlanner(), dependencyManager, sbomGenerator, scribe.NewEmitter(buffer), chronos.DefaultClock)
+		})
+
+		it("selects the version of the highest priority source that requests one", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("12.x"))
+			Expect(buffer.String()).To(ContainSubstring("Selected Node Engine version (using package.json)"))
+		})
+	})
+
+	context("when the .tool-versions file lists fallback versions", func() {
+		it.Before(func() {
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "10.11.12 || 12.13.14",
+					"version-source": ".tool-versions",
+				},
+			}
+		})
+
+		it("installs the first version that is available, even when a later one is higher", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("10.11.12"))
+		})
+
+		context("when the first version is not available", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "11.0.0 || 12.13.14 || 10.11.12"
+			})
+
+			it("falls back to the next version in order", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("12.13.14"))
+			})
+		})
+	})
+
+	context("when the plan contains a devEngines.runtime requirement", func() {
+		var devEnginesEntry packit.BuildpackPlanEntry
+
+		it.Before(func() {
+			devEnginesEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "^22",
+					"version-source": "devEngines",
+					"on-fail":        "error",
+				},
+			}
+		})
+
+		context("and the selected version satisfies it", func() {
+			it.Before(func() {
+				dependencyManager.ResolveCall.Returns.Dependency = postal.Dependency{Name: "Node Engine", Version: "22.3.0"}
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{devEnginesEntry}
+			})
+
+			it("installs the selected version", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dependencyManager.DeliverCall.CallCount).To(Equal(1))
+			})
+		})
+
+		context("and the selected version does not satisfy it", func() {
+			it.Before(func() {
+				dependencyManager.ResolveCall.Returns.Dependency = postal.Dependency{Name: "Node Engine", Version: "20.11.1"}
+			})
+
+			context("when onFail is ignore", func() {
+				it.Before(func() {
+					devEnginesEntry.Metadata["on-fail"] = "ignore"
+					entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{devEnginesEntry}
+				})
+
+				it("installs the selected version silently", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(dependencyManager.DeliverCall.CallCount).To(Equal(1))
+					Expect(buffer.String()).NotTo(ContainSubstring("devEngines.runtime"))
+				})
+			})
+
+			context("when onFail is warn", func() {
+				it.Before(func() {
+					devEnginesEntry.Metadata["on-fail"] = "warn"
+					entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{devEnginesEntry}
+				})
+
+				it("installs the selected version with a warning", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(dependencyManager.DeliverCall.CallCount).To(Equal(1))
+					Expect(buffer.String()).To(ContainSubstring(`WARNING: Node Engine 20.11.1 does not satisfy devEngines.runtime version "^22" from package.json.`))
+				})
+			})
+
+			context("when onFail is error", func() {
+				it.Before(func() {
+					entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{devEnginesEntry}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`selected Node Engine version 20.11.1 does not satisfy devEngines.runtime version "^22" from package.json (onFail: error)`))
+					Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+				})
+			})
+		})
+
+		context("when it is the selected requirement and no version satisfies it", func() {
+			var nvmrcEntry packit.BuildpackPlanEntry
+
+			it.Before(func() {
+				devEnginesEntry.Metadata["on-fail"] = "warn"
+				nvmrcEntry = packit.BuildpackPlanEntry{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "12.*",
+						"version-source": ".nvmrc",
+					},
+				}
+
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry = devEnginesEntry
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{devEnginesEntry, nvmrcEntry}
+				dependencyManager.ResolveCall.Returns.Dependency = postal.Dependency{Name: "Node Engine", Version: "12.13.14"}
+			})
+
+			context("when onFail is warn", func() {
+				it("falls back to the next version source and warns", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("12.*"))
+					Expect(buffer.String()).To(ContainSubstring("Selected Node Engine version (using .nvmrc): 12.13.14"))
+					Expect(buffer.String()).To(ContainSubstring(`WARNING: Node Engine 12.13.14 does not satisfy devEngines.runtime version "^22" from package.json.`))
+				})
+			})
+
+			context("when onFail is ignore and there is no other version source", func() {
+				it.Before(func() {
+					devEnginesEntry.Metadata["on-fail"] = "ignore"
+					entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{devEnginesEntry}
+				})
+
+				it("installs the default version", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("default"))
+					Expect(dependencyManager.DeliverCall.CallCount).To(Equal(1))
+				})
+			})
+
+			context("when onFail is error", func() {
+				it.Before(func() {
+					devEnginesEntry.Metadata["on-fail"] = "error"
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring(`no version of node satisfies "^22" from devEngines`)))
+				})
+			})
+		})
+	})
+
+	context("when BP_NODE_VERSION_RESOLUTION is set to intersect", func() {
+		it.Before(func() {
+			t.Setenv("BP_NODE_VERSION_RESOLUTION", "intersect")
+			t.Setenv("CNB_TARGET_OS", "linux")
+			t.Setenv("CNB_TARGET_ARCH", "amd64")
+
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "20.10.0"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "20.11.1"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "20.12.0"
+  stacks = ["*"]
+  os = "linux"
+  arch = "arm64"
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "22.1.0"
+  stacks = ["some-stack"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "22.2.0"
+  stacks = ["other-stack"]
+`), 0600)).To(Succeed())
+
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "20.*",
+						"version-source": ".nvmrc",
+					},
+				},
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        ">=20.10",
+						"version-source": "package.json",
+					},
+				},
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "^22",
+						"version-source": "devEngines",
+						"on-fail":        "warn",
+					},
+				},
+			}
+		})
+
+		it("resolves the newest version that satisfies every requirement", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.Receives.Path).To(Equal(filepath.Join(cnbDir, "buildpack.toml")))
+			Expect(dependencyManager.ResolveCall.Receives.Id).To(Equal("node"))
+			Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("20.11.1"))
+			Expect(dependencyManager.ResolveCall.Receives.Stack).To(Equal("some-stack"))
+
+			Expect(buffer.String()).To(ContainSubstring("Intersecting the version requirements of all candidate version sources"))
+			Expect(buffer.String()).To(ContainSubstring("Selected Node Engine version (using .nvmrc, package.json): 10.11.12"))
+		})
+
+		context("when none of the requirements specify a version", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "20.*"
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+					{
+						Name:     "node",
+						Metadata: map[string]interface{}{},
+					},
+				}
+			})
+
+			it("resolves the entry selected by priority", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("20.*"))
+			})
+		})
+
+		context("when no version satisfies every requirement", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        "20.*",
+							"version-source": ".nvmrc",
+						},
+					},
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        "^22",
+							"version-source": "package.json",
+						},
+					},
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        "18.*",
+							"version-source": "BP_NODE_VERSION",
+						},
+					},
+				}
+			})
+
+			it("returns an error listing every requirement", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(nodeengine.IntersectionError{
+					Requirements: []nodeengine.IntersectionRequirement{
+						{Source: ".nvmrc", Version: "20.*", Matches: []string{"20.11.1", "20.10.0"}},
+						{Source: "package.json", Version: "^22", Matches: []string{"22.1.0"}},
+						{Source: "BP_NODE_VERSION", Version: "18.*"},
+					},
+				}))
+				Expect(err.Error()).To(Equal(`no version of node satisfies every version requirement:
+  .nvmrc          -> "20.*" (matches 20.11.1, 20.10.0)
+  package.json    -> "^22" (matches 22.1.0)
+  BP_NODE_VERSION -> "18.*" (no matching versions)`))
+				Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when a requirement lists asdf alternatives", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        "20.11 || 22.1",
+							"version-source": ".tool-versions",
+						},
+					},
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        ">=20",
+							"version-source": "package.json",
+						},
+					},
+				}
+			})
+
+			it("intersects the first available alternative", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("20.11.1"))
+			})
+		})
+
+		context("when BP_NODE_DIST_MIRROR is set and no dependency satisfies every requirement", func() {
+			var (
+				server   *httptest.Server
+				checksum = "c4f5e9c1b8d7f6e5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1"
+			)
+
+			it.Before(func() {
+				signer := writeReleaseKeys(t, filepath.Join(cnbDir, "release-keys.asc"))
+
+				shasums := fmt.Sprintf("%s  node-v20.12.1-linux-x64.tar.xz\n", checksum)
+				signature := detachSign(t, signer, shasums)
+
+				server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					switch req.URL.Path {
+					case "/dist/index.json":
+						fmt.Fprint(w, `[
+							{"version": "v22.1.0", "files": ["linux-x64"]},
+							{"version": "v20.13.0", "files": ["linux-arm64"]},
+							{"version": "v20.12.1", "files": ["linux-x64"]}
+						]`)
+					case "/dist/v20.12.1/SHASUMS256.txt":
+						fmt.Fprint(w, shasums)
+					case "/dist/v20.12.1/SHASUMS256.txt.sig":
+						_, _ = w.Write(signature)
+					default:
+						http.NotFound(w, req)
+					}
+				}))
+
+				t.Setenv("BP_NODE_DIST_MIRROR", server.URL+"/dist")
+
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        "18.* || 20.*",
+							"version-source": ".nvmrc",
+						},
+					},
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        ">=20.12",
+							"version-source": "package.json",
+						},
+					},
+				}
+			})
+
+			it.After(func() {
+				server.Close()
+			})
+
+			it("installs the newest release of the mirror that satisfies every requirement", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+				Expect(dependencyManager.DeliverCall.Receives.Dependency.URI).To(Equal(fmt.Sprintf("%s/dist/v20.12.1/node-v20.12.1-linux-x64.tar.xz", server.URL)))
+				Expect(dependencyManager.DeliverCall.Receives.Dependency.Checksum).To(Equal(fmt.Sprintf("sha256:%s", checksum)))
+
+				Expect(buffer.String()).To(ContainSubstring(`No version in the buildpack.toml satisfies "18.*, >=20.12 || 20.*, >=20.12", resolved 20.12.1 from BP_NODE_DIST_MIRROR`))
+			})
+
+			context("when the mirror has no release that satisfies every requirement either", func() {
+				it.Before(func() {
+					entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice[1].Metadata["version"] = ">=20.14 <22"
+				})
+
+				it("returns an error listing every requirement", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(nodeengine.IntersectionError{
+						Requirements: []nodeengine.IntersectionRequirement{
+							{Source: ".nvmrc", Version: "18.* || 20.*", Matches: []string{"20.11.1", "20.10.0"}},
+							{Source: "package.json", Version: ">=20.14 <22"},
+						},
+					}))
+					Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+				})
+			})
+		})
+
+		context("when a requirement is not a valid version constraint", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        "not-a-version",
+							"version-source": ".nvmrc",
+						},
+					},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`invalid version constraint "not-a-version" from .nvmrc`)))
+			})
+		})
+
+		context("when the buildpack.toml cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte("%%%"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse buildpack.toml")))
+			})
+		})
+	})
+
+	context("when the plan entries come from several workspaces", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "20.10.0"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "20.11.1"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "22.1.0"
+  stacks = ["*"]
+`), 0600)).To(Succeed())
+
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "20.*",
+					"version-source": ".nvmrc",
+					"workspace":      ".",
+				},
+			}
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "20.*",
+						"version-source": ".nvmrc",
+						"workspace":      ".",
+					},
+				},
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "*",
+						"version-source": ".nvmrc",
+						"workspace":      "apps/api",
+					},
+				},
+				{
+					Name: "node",
+					Metadata: map[string]interface{}{
+						"version":        "~20.10",
+						"version-source": "package.json",
+						"workspace":      "apps/api",
+					},
+				},
+			}
+		})
+
+		it("resolves the newest version that satisfies the highest priority requirement of every workspace", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("20.10.0"))
+
+			Expect(buffer.String()).To(ContainSubstring("Intersecting the version requirements of workspaces ., apps/api"))
+			Expect(buffer.String()).To(ContainSubstring("Selected Node Engine version (using .nvmrc (.), package.json (apps/api)): 10.11.12"))
+		})
+
+		context("when no version satisfies every workspace", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice[2].Metadata["version"] = "^22"
+			})
+
+			it("returns an error listing the requirement of every workspace", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(nodeengine.IntersectionError{
+					Requirements: []nodeengine.IntersectionRequirement{
+						{Source: ".nvmrc (.)", Version: "20.*", Matches: []string{"20.11.1", "20.10.0"}},
+						{Source: "package.json (apps/api)", Version: "^22", Matches: []string{"22.1.0"}},
+					},
+				}))
+				Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("when no available version satisfies the selected requirement", func() {
+		it.Before(func() {
+			t.Setenv("CNB_TARGET_OS", "linux")
+			t.Setenv("CNB_TARGET_ARCH", "amd64")
+
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "~11.2",
+					"version-source": "BP_NODE_VERSION",
+				},
+			}
+		})
+
+		it("returns an error listing the available versions and the nearest release line", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(nodeengine.NoMatchingVersionError{
+				Source:         "BP_NODE_VERSION",
+				Version:        "~11.2",
+				Platform:       "linux/amd64",
+				Available:      []string{"12.13.14", "10.11.12"},
+				NearestLine:    "12.*",
+				NearestVersion: "12.13.14",
+			}))
+			Expect(err.Error()).To(Equal(`no version of node satisfies "~11.2" from BP_NODE_VERSION
+  available versions for linux/amd64: 12.13.14, 10.11.12
+  nearest release line: "12.*" (12.13.14)`))
+			Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+		})
+
+		context("when the requirement is closer to an older release line", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = ">=9 <10"
+			})
+
+			it("suggests that line", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`nearest release line: "10.*" (10.11.12)`)))
+			})
+		})
+
+		context("when no versions are available for the platform", func() {
+			it.Before(func() {
+				buildContext.Stack = "other-stack"
+			})
+
+			it("says so", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`no version of node satisfies "~11.2" from BP_NODE_VERSION
+  no versions are available for linux/amd64`))
+			})
+		})
+	})
+
+	context("when BP_NODE_VERSION_FALLBACK is set", func() {
+		it.Before(func() {
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "10.2.3",
+					"version-source": ".nvmrc",
+				},
+			}
+		})
+
+		context("when it is set to same-major", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_FALLBACK", "same-major")
+			})
+
+			it("installs the newest version of the same major line and records the substitution", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("10.*"))
+
+				Expect(result.Layers).To(HaveLen(1))
+				Expect(result.Layers[0].Metadata[nodeengine.FallbackKey]).To(Equal(nodeengine.VersionFallback{
+					Policy:    "same-major",
+					Source:    ".nvmrc",
+					Requested: "10.2.3",
+					Fallback:  "10.*",
+					Resolved:  "10.11.12",
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: Node version "10.2.3" requested by .nvmrc is not available.`))
+				Expect(buffer.String()).To(ContainSubstring(`Installing 10.11.12 instead, as allowed by BP_NODE_VERSION_FALLBACK=same-major ("10.*")`))
+			})
+		})
+
+		context("when it is set to same-minor and no version of that minor release is available", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_FALLBACK", "same-minor")
+			})
+
+			it("returns the error for the requested version", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`no version of node satisfies "10.2.3" from .nvmrc`)))
+				Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when it is set to same-minor and a version of that minor release is available", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_FALLBACK", "same-minor")
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "10.11.0"
+			})
+
+			it("installs the newest patch of that minor release", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("10.11.*"))
+			})
+		})
+
+		context("when the requested version has a range operator", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_FALLBACK", "same-major")
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "~10.2"
+			})
+
+			it("loosens it", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("10.*"))
+			})
+		})
+
+		context("when the requested version is bounded", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_FALLBACK", "same-major")
+			})
+
+			it("returns the error for the requested version instead of loosening an upper bound", func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "<10.2"
+
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`no version of node satisfies "<10.2" from .nvmrc`)))
+				Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+			})
+
+			it("returns the error for the requested version instead of loosening a range", func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = ">=10 <10.3"
+
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`no version of node satisfies ">=10 <10.3" from .nvmrc`)))
+				Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the requested version is available", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_FALLBACK", "same-major")
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "10.11.12"
+			})
+
+			it("does not substitute it", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("10.11.12"))
+				Expect(result.Layers[0].Metadata).NotTo(HaveKey(nodeengine.FallbackKey))
+				Expect(buffer.String()).NotTo(ContainSubstring("BP_NODE_VERSION_FALLBACK"))
+			})
+		})
+
+		context("when it is set to an invalid value", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_FALLBACK", "anything")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`invalid value for BP_NODE_VERSION_FALLBACK: "anything", expected "fail", "same-minor" or "same-major"`))
+			})
+		})
+	})
+
+	context("when the selected version is past its deprecation date", func() {
+		it.Before(func() {
+			dependencyManager.ResolveCall.Returns.Dependency = postal.Dependency{
+				Name:            "Node Engine",
+				Version:         "10.11.12",
+				DeprecationDate: time.Date(2024, time.April, 30, 0, 0, 0, 0, time.UTC),
+			}
+
+			now := time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC)
+			build = nodeengine.Build(entryResolver, dependencyManager, sbomGenerator, scribe.NewEmitter(buffer), chronos.NewClock(func() time.Time { return now }))
+		})
+
+		it("only warns about the deprecation once by default", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("Version 10.11.12 of Node Engine is deprecated."))
+			Expect(buffer.String()).NotTo(ContainSubstring("reached its end of life"))
+			Expect(buffer.String()).NotTo(ContainSubstring("grace period"))
+		})
+
+		context("when BP_NODE_EOL_POLICY is set to ignore", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_EOL_POLICY", "ignore")
+			})
+
+			it("does not warn", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Selected Node Engine version (using BP_NODE_VERSION): 10.11.12"))
+				Expect(buffer.String()).NotTo(ContainSubstring("deprecated"))
+				Expect(buffer.String()).NotTo(ContainSubstring("reached its end of life"))
+			})
+		})
+
+		context("when BP_NODE_EOL_GRACE_DAYS is set with the default policy", func() {
+			context("when the grace period has not ended", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_EOL_GRACE_DAYS", "30")
+				})
+
+				it("reports when the grace period ends", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(buffer.String()).To(ContainSubstring("Version 10.11.12 of Node Engine is deprecated."))
+					Expect(buffer.String()).To(ContainSubstring("The BP_NODE_EOL_GRACE_DAYS grace period for Node Engine 10.11.12 ends after 2024-05-30."))
+					Expect(buffer.String()).NotTo(ContainSubstring("WARNING: The BP_NODE_EOL_GRACE_DAYS grace period"))
+				})
+			})
+
+			context("when the grace period has ended", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_EOL_GRACE_DAYS", "5")
+				})
+
+				it("warns that the grace period has ended", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(buffer.String()).To(ContainSubstring("WARNING: The BP_NODE_EOL_GRACE_DAYS grace period for Node Engine 10.11.12 ended on 2024-05-05."))
+				})
+			})
+		})
+
+		context("when BP_NODE_EOL_POLICY is set to fail", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_EOL_POLICY", "fail")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`Node Engine 10.11.12 reached its end of life on 2024-04-30, and BP_NODE_EOL_POLICY is set to "fail": select a supported version`))
+				Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+			})
+
+			context("when the grace period has not ended", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_EOL_GRACE_DAYS", "30")
+				})
+
+				it("warns about the end of the grace period", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(buffer.String()).To(ContainSubstring("Version 10.11.12 of Node Engine is deprecated."))
+					Expect(buffer.String()).To(ContainSubstring("Builds using Node Engine 10.11.12 will fail after 2024-05-30, when the BP_NODE_EOL_GRACE_DAYS grace period ends."))
+					Expect(buffer.String()).NotTo(ContainSubstring("reached its end of life"))
+				})
+			})
+
+			context("when the grace period has ended", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_EOL_GRACE_DAYS", "10")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("reached its end of life on 2024-04-30")))
+				})
+			})
+		})
+
+		context("when the selected version is not yet deprecated", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_EOL_POLICY", "fail")
+				dependencyManager.ResolveCall.Returns.Dependency.DeprecationDate = time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+			})
+
+			it("installs it", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).NotTo(ContainSubstring("reached its end of life"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when BP_NODE_EOL_POLICY is set to an invalid value", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_EOL_POLICY", "block")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`invalid value for BP_NODE_EOL_POLICY: "block", expected "ignore", "warn" or "fail"`))
+				})
+			})
+
+			context("when BP_NODE_EOL_GRACE_DAYS is not a number of days", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_EOL_GRACE_DAYS", "1w")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_EOL_GRACE_DAYS value 1w")))
+				})
+			})
+
+			context("when BP_NODE_EOL_GRACE_DAYS is negative", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_EOL_GRACE_DAYS", "-1")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError("failed to parse BP_NODE_EOL_GRACE_DAYS value -1: must not be negative"))
+				})
+			})
+		})
+	})
+
+	context("when a newer security release of the selected version's line is available", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "10.11.12"
+  stacks = ["some-stack"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "10.11.13"
+  security = true
+  stacks = ["some-stack"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "12.13.14"
+  security = true
+  stacks = ["some-stack"]
+`), 0600)).To(Succeed())
+
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "10.11.12",
+					"version-source": ".nvmrc",
+				},
+			}
+		})
+
+		it("warns by default", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("WARNING: Node Engine 10.11.12 is older than 10.11.13, the newest security release of the 10.x line."))
+			Expect(buffer.String()).To(ContainSubstring("Update the version requested by .nvmrc to install the security fixes."))
+		})
+
+		context("when BP_NODE_SECURITY_POLICY is set to ignore", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_SECURITY_POLICY", "ignore")
+			})
+
+			it("does not warn", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).NotTo(ContainSubstring("security release"))
+			})
+		})
+
+		context("when BP_NODE_SECURITY_POLICY is set to fail", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_SECURITY_POLICY", "fail")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`Node Engine 10.11.12 is older than 10.11.13, the newest security release of the 10.x line, and BP_NODE_SECURITY_POLICY is set to "fail": update the version requested by .nvmrc`))
+				Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the selected version is the newest security release of its line", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_SECURITY_POLICY", "fail")
+				dependencyManager.ResolveCall.Returns.Dependency.Version = "10.11.13"
+			})
+
+			it("installs it", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).NotTo(ContainSubstring("security release"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when BP_NODE_SECURITY_POLICY is set to an invalid value", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_SECURITY_POLICY", "block")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`invalid value for BP_NODE_SECURITY_POLICY: "block", expected "ignore", "warn" or "fail"`))
+				})
+			})
+
+			context("when the security field of a dependency cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "10.11.12"
+  security = "yes"
+  stacks = ["some-stack"]
+`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to read security releases")))
+				})
+			})
+		})
+	})
+
+	context("when a pre-release version is requested", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "10.11.12"
+  stacks = ["some-stack"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "23.0.0-rc.1"
+  stacks = ["some-stack"]
+`), 0600)).To(Succeed())
+
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "23.0.0-rc.1",
+					"version-source": ".nvmrc",
+				},
+			}
+			dependencyManager.ResolveCall.Returns.Dependency.Version = "23.0.0-rc.1"
+		})
+
+		it("returns an error", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(`pre-release version "23.0.0-rc.1" of node requested by .nvmrc is not allowed: set BP_NODE_ALLOW_PRERELEASE=true to install it`))
+			Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+		})
+
+		context("when BP_NODE_ALLOW_PRERELEASE is set", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_ALLOW_PRERELEASE", "true")
+			})
+
+			it("installs it", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("23.0.0-rc.1"))
+			})
+		})
+
+		context("when BP_NODE_ALLOW_PRERELEASE is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_ALLOW_PRERELEASE", "maybe")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_ALLOW_PRERELEASE value maybe")))
+			})
+		})
+	})
+
+	context("when a release line is requested and only a pre-release of it is available", func() {
+		it.Before(func() {
+			t.Setenv("BP_NODE_ALLOW_PRERELEASE", "true")
+
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "23.0.0-rc.1"
+  stacks = ["some-stack"]
+`), 0600)).To(Succeed())
+
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "23.*"
+		})
+
+		it("does not select the pre-release", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(ContainSubstring(`no version of node satisfies "23.*" from BP_NODE_VERSION`)))
+		})
+	})
+
+	context("when BP_NODE_DISTRIBUTION_URL is set", func() {
+		var checksum = "a2f5e9c1b8d7f6e5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1"
+
+		it.Before(func() {
+			t.Setenv("BP_NODE_DISTRIBUTION_URL", "https://example.com/builds/node-v22.11.0-linux-x64.tar.xz")
+			t.Setenv("BP_NODE_DISTRIBUTION_SHA256", checksum)
+
+			dependencyManager.DeliverCall.Stub = func(_ postal.Dependency, _, layerPath, _ string) error {
+				Expect(os.MkdirAll(filepath.Join(layerPath, "node-v22.11.0-linux-x64", "bin"), os.ModePerm)).To(Succeed())
+				return os.WriteFile(filepath.Join(layerPath, "node-v22.11.0-linux-x64", "bin", "node"), nil, 0755)
+			}
+		})
+
+		it("installs the distribution instead of resolving a version", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+			Expect(dependencyManager.DeliverCall.Receives.Dependency).To(Equal(postal.Dependency{
+				ID:       "node",
+				Name:     "Node Engine",
+				Version:  "22.11.0",
+				URI:      "https://example.com/builds/node-v22.11.0-linux-x64.tar.xz",
+				Source:   "https://example.com/builds/node-v22.11.0-linux-x64.tar.xz",
+				Checksum: fmt.Sprintf("sha256:%s", checksum),
+				CPE:      "cpe:2.3:a:nodejs:node.js:22.11.0:*:*:*:*:*:*:*",
+				PURL:     fmt.Sprintf("pkg:generic/node@v22.11.0?checksum=%s&download_url=https://example.com/builds/node-v22.11.0-linux-x64.tar.xz", checksum),
+				Stacks:   []string{"*"},
+			}))
+			Expect(sbomGenerator.GenerateFromDependencyCall.Receives.Dependency.Version).To(Equal("22.11.0"))
+
+			Expect(result.Layers[0].Metadata[nodeengine.DepKey]).To(Equal(fmt.Sprintf("sha256:%s", checksum)))
+			Expect(filepath.Join(layersDir, "node", "bin", "node")).To(BeARegularFile())
+			Expect(filepath.Join(layersDir, "node", "node-v22.11.0-linux-x64")).NotTo(BeADirectory())
+
+			Expect(buffer.String()).To(ContainSubstring("Installing the distribution at https://example.com/builds/node-v22.11.0-linux-x64.tar.xz, version requirements are ignored"))
+			Expect(buffer.String()).To(ContainSubstring("Selected Node Engine version (using BP_NODE_DISTRIBUTION_URL): 22.11.0"))
+		})
+
+		context("when the plan contains a devEngines.runtime requirement that the distribution does not satisfy", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = []packit.BuildpackPlanEntry{
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"version":        "^20",
+							"version-source": "devEngines",
+							"on-fail":        "error",
+						},
+					},
+				}
+			})
+
+			it("installs the distribution", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.DeliverCall.Receives.Dependency.Version).To(Equal("22.11.0"))
+				Expect(buffer.String()).NotTo(ContainSubstring("devEngines.runtime"))
+			})
+		})
+
+		context("when the distribution has no top-level directory", func() {
+			it.Before(func() {
+				dependencyManager.DeliverCall.Stub = func(_ postal.Dependency, _, layerPath, _ string) error {
+					Expect(os.MkdirAll(filepath.Join(layerPath, "bin"), os.ModePerm)).To(Succeed())
+					return os.WriteFile(filepath.Join(layerPath, "bin", "node"), nil, 0755)
+				}
+			})
+
+			it("leaves it as it is", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(layersDir, "node", "bin", "node")).To(BeARegularFile())
+			})
+		})
+
+		context("when the checksum is read from a SHASUMS256.txt file", func() {
+			var (
+				server     *httptest.Server
+				signatures map[string][]byte
+			)
+
+			it.Before(func() {
+				signer := writeReleaseKeys(t, filepath.Join(cnbDir, "release-keys.asc"))
+
+				shasums := fmt.Sprintf("%s  node-v22.11.0-darwin-x64.tar.xz\n%s  node-v22.11.0-linux-x64.tar.xz\n", strings.Repeat("0", 64), checksum)
+				signatures = map[string][]byte{"/builds/SHASUMS256.txt.sig": detachSign(t, signer, shasums)}
+
+				server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					if req.URL.Path == "/builds/SHASUMS256.txt" {
+						fmt.Fprint(w, shasums)
+						return
+					}
+
+					signature, ok := signatures[req.URL.Path]
+					if !ok {
+						http.NotFound(w, req)
+						return
+					}
+					_, _ = w.Write(signature)
+				}))
+
+				t.Setenv("BP_NODE_DISTRIBUTION_SHA256", "")
+				t.Setenv("BP_NODE_DISTRIBUTION_SHASUMS_URL", server.URL+"/builds/SHASUMS256.txt")
+			})
+
+			it.After(func() {
+				server.Close()
+			})
+
+			it("verifies the distribution against the listed checksum", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.DeliverCall.Receives.Dependency.Checksum).To(Equal(fmt.Sprintf("sha256:%s", checksum)))
+			})
+
+			context("when the file is not listed", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DISTRIBUTION_URL", "https://example.com/builds/node-v22.11.0-linux-arm64.tar.xz")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(fmt.Sprintf("no checksum for node-v22.11.0-linux-arm64.tar.xz found in %s/builds/SHASUMS256.txt", server.URL)))
+				})
+			})
+
+			context("when the file cannot be fetched", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DISTRIBUTION_SHASUMS_URL", server.URL+"/missing/SHASUMS256.txt")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(Equal(fmt.Sprintf("failed to fetch %s/missing/SHASUMS256.txt: unexpected status code 404", server.URL))))
+				})
+			})
+
+			context("when the file is not signed", func() {
+				it.Before(func() {
+					signatures = map[string][]byte{}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(fmt.Sprintf("no signature found for %[1]s/builds/SHASUMS256.txt: expected %[1]s/builds/SHASUMS256.txt.sig or %[1]s/builds/SHASUMS256.txt.asc", server.URL)))
+					Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+				})
+			})
+
+			context("when the release keys are missing", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(cnbDir, "release-keys.asc"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to read Node release keys")))
+				})
+			})
+		})
+
+		context("when the version is not part of the file name", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_DISTRIBUTION_URL", "https://example.com/builds/node-patched.tar.xz")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`failed to read the Node version from the file name of BP_NODE_DISTRIBUTION_URL "node-patched.tar.xz": set BP_NODE_DISTRIBUTION_VERSION`))
+			})
+
+			context("when BP_NODE_DISTRIBUTION_VERSION is set", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DISTRIBUTION_VERSION", "v22.11.1")
+				})
+
+				it("uses that version", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.DeliverCall.Receives.Dependency.Version).To(Equal("22.11.1"))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when no checksum is given", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DISTRIBUTION_SHA256", "")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError("BP_NODE_DISTRIBUTION_URL requires BP_NODE_DISTRIBUTION_SHA256 or BP_NODE_DISTRIBUTION_SHASUMS_URL to verify the download"))
+				})
+			})
+
+			context("when both a checksum and a SHASUMS256.txt file are given", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DISTRIBUTION_SHASUMS_URL", "https://example.com/builds/SHASUMS256.txt")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError("BP_NODE_DISTRIBUTION_SHA256 and BP_NODE_DISTRIBUTION_SHASUMS_URL cannot both be set"))
+				})
+			})
+
+			context("when the checksum is not a SHA-256 checksum", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DISTRIBUTION_SHA256", "not-a-checksum")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`invalid value for BP_NODE_DISTRIBUTION_SHA256: "not-a-checksum", expected a hex-encoded SHA-256 checksum`))
+				})
+			})
+
+			context("when the URL is not an http or https URL", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DISTRIBUTION_URL", "file:///tmp/node-v22.11.0-linux-x64.tar.xz")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`invalid value for BP_NODE_DISTRIBUTION_URL: "file:///tmp/node-v22.11.0-linux-x64.tar.xz", expected an http or https URL`))
+				})
+			})
+
+			context("when the download does not match the checksum", func() {
+				it.Before(func() {
+					dependencyManager.DeliverCall.Stub = nil
+					dependencyManager.DeliverCall.Returns.Error = errors.New("failed to validate dependency: checksum does not match")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError("failed to install Node from BP_NODE_DISTRIBUTION_URL https://example.com/builds/node-v22.11.0-linux-x64.tar.xz: failed to validate dependency: checksum does not match"))
+				})
+			})
+
+			context("when the distribution does not contain node", func() {
+				it.Before(func() {
+					dependencyManager.DeliverCall.Stub = func(_ postal.Dependency, _, layerPath, _ string) error {
+						return os.MkdirAll(filepath.Join(layerPath, "some-dir"), os.ModePerm)
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("expected the distribution to contain bin/node at its root or inside a single top-level directory")))
+				})
+			})
+		})
+	})
+
+	context("when BP_NODE_DIST_MIRROR is set", func() {
+		var (
+			server     *httptest.Server
+			signatures map[string][]byte
+			checksum   = "b3f5e9c1b8d7f6e5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1"
+		)
+
+		it.Before(func() {
+			t.Setenv("CNB_TARGET_OS", "linux")
+			t.Setenv("CNB_TARGET_ARCH", "amd64")
+
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "10.11.12"
+  stacks = ["some-stack"]
+  os = "linux"
+  arch = "amd64"
+  deprecation_date = 2099-04-30T00:00:00Z
+`), 0600)).To(Succeed())
+
+			signer := writeReleaseKeys(t, filepath.Join(cnbDir, "release-keys.asc"))
+
+			shasums := fmt.Sprintf("%s  node-v10.12.1-linux-arm64.tar.xz\n%s  node-v10.12.1-linux-x64.tar.xz\n", strings.Repeat("0", 64), checksum)
+			signatures = map[string][]byte{"/dist/v10.12.1/SHASUMS256.txt.sig": detachSign(t, signer, shasums)}
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/dist/index.json":
+					fmt.Fprint(w, `[
+						{"version": "v10.13.0", "files": ["linux-arm64"]},
+						{"version": "v10.12.1", "files": ["linux-arm64", "linux-x64"]},
+						{"version": "v10.12.0", "files": ["linux-x64"]},
+						{"version": "v9.11.2", "files": ["linux-x64"]}
+					]`)
+				case "/dist/v10.12.1/SHASUMS256.txt":
+					fmt.Fprint(w, shasums)
+				default:
+					signature, ok := signatures[req.URL.Path]
+					if !ok {
+						http.NotFound(w, req)
+						return
+					}
+					_, _ = w.Write(signature)
+				}
+			}))
+
+			t.Setenv("BP_NODE_DIST_MIRROR", server.URL+"/dist/")
+
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "10.12.*",
+					"version-source": ".nvmrc",
+				},
+			}
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("installs the newest matching release of the mirror for the target platform", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.CallCount).To(Equal(0))
+
+			uri := fmt.Sprintf("%s/dist/v10.12.1/node-v10.12.1-linux-x64.tar.xz", server.URL)
+			Expect(dependencyManager.DeliverCall.Receives.Dependency).To(Equal(postal.Dependency{
+				ID:              "node",
+				Name:            "Node Engine",
+				Version:         "10.12.1",
+				URI:             uri,
+				Source:          uri,
+				Checksum:        fmt.Sprintf("sha256:%s", checksum),
+				CPE:             "cpe:2.3:a:nodejs:node.js:10.12.1:*:*:*:*:*:*:*",
+				PURL:            fmt.Sprintf("pkg:generic/node@v10.12.1?checksum=%s&download_url=%s", checksum, uri),
+				Stacks:          []string{"*"},
+				StripComponents: 1,
+				DeprecationDate: time.Date(2099, time.April, 30, 0, 0, 0, 0, time.UTC),
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring(`No version in the buildpack.toml satisfies "10.12.*", resolved 10.12.1 from BP_NODE_DIST_MIRROR`))
+		})
+
+		context("when the buildpack.toml satisfies the requested version", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "10.*"
+			})
+
+			it("does not consult the mirror", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("10.*"))
+				Expect(buffer.String()).NotTo(ContainSubstring("BP_NODE_DIST_MIRROR"))
+			})
+		})
+
+		context("when the mirror has no matching release either", func() {
+			it.Before(func() {
+				entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "10.13.*"
+			})
+
+			it("returns the error for the requested version", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring(`no version of node satisfies "10.13.*" from .nvmrc`)))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the index cannot be fetched", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DIST_MIRROR", server.URL+"/missing")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(fmt.Sprintf("failed to resolve Node version from BP_NODE_DIST_MIRROR: failed to fetch %s/missing/index.json: unexpected status code 404", server.URL)))
+				})
+			})
+
+			context("when the SHASUMS256.txt file is not signed", func() {
+				it.Before(func() {
+					signatures = map[string][]byte{}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(fmt.Sprintf("failed to resolve Node version from BP_NODE_DIST_MIRROR: no signature found for %[1]s/dist/v10.12.1/SHASUMS256.txt: expected %[1]s/dist/v10.12.1/SHASUMS256.txt.sig or %[1]s/dist/v10.12.1/SHASUMS256.txt.asc", server.URL)))
+					Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+				})
+			})
+
+			context("when the release keys are missing", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(cnbDir, "release-keys.asc"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to read Node release keys")))
+				})
+			})
+
+			context("when the mirror is not an http or https URL", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_DIST_MIRROR", "/srv/node/dist")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`invalid value for BP_NODE_DIST_MIRROR: "/srv/node/dist", expected an http or https URL`))
+				})
+			})
+		})
+	})
+
+	context("when the plan requests an npm version", func() {
+		var delivered []string
+
+		it.Before(func() {
+			buildContext.Plan.Entries = append(buildContext.Plan.Entries, packit.BuildpackPlanEntry{
+				Name: "npm",
+				Metadata: map[string]interface{}{
+					"version":        "10.9.*",
+					"version-source": "package.json",
+				},
+			})
+
+			entryResolver.ResolveCall.Stub = func(name string, entries []packit.BuildpackPlanEntry, _ []interface{}) (packit.BuildpackPlanEntry, []packit.BuildpackPlanEntry) {
+				if name == "npm" {
+					return entries[0], entries
+				}
+				return entryResolver.ResolveCall.Returns.BuildpackPlanEntry, entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice
+			}
+
+			dependencyManager.ResolveCall.Stub = func(_, id, _, _ string) (postal.Dependency, error) {
+				if id == "npm" {
+					return postal.Dependency{ID: "npm", Name: "npm", Version: "10.9.2", Checksum: "sha256:npm-sha"}, nil
+				}
+				return postal.Dependency{Name: "Node Engine", Version: "10.11.12"}, nil
+			}
+
+			delivered = nil
+			dependencyManager.DeliverCall.Stub = func(dependency postal.Dependency, _, layerPath, _ string) error {
+				delivered = append(delivered, fmt.Sprintf("%s %s", dependency.Version, layerPath))
+				return nil
+			}
+		})
+
+		it("installs the requested npm into its own layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.Receives.Id).To(Equal("npm"))
+			Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("10.9.*"))
+			Expect(delivered).To(Equal([]string{
+				fmt.Sprintf("10.11.12 %s", filepath.Join(layersDir, "node")),
+				fmt.Sprintf("10.9.2 %s", filepath.Join(layersDir, "npm")),
+			}))
+			Expect(dependencyManager.GenerateBillOfMaterialsCall.Receives.Dependencies).To(Equal([]postal.Dependency{
+				{Name: "Node Engine", Version: "10.11.12"},
+				{ID: "npm", Name: "npm", Version: "10.9.2", Checksum: "sha256:npm-sha"},
+			}))
+			Expect(sbomGenerator.GenerateFromDependencyCall.CallCount).To(Equal(2))
+			Expect(sbomGenerator.GenerateFromDependencyCall.Receives.Dir).To(Equal(filepath.Join(layersDir, "npm")))
+
+			Expect(result.Layers).To(HaveLen(2))
+			layer := result.Layers[1]
+			Expect(layer.Name).To(Equal("npm"))
+			Expect(layer.Metadata).To(Equal(map[string]interface{}{
+				nodeengine.DepKey:    "sha256:npm-sha",
+				nodeengine.BuildKey:  false,
+				nodeengine.LaunchKey: false,
+			}))
+			Expect(layer.SBOM.Formats()).To(HaveLen(2))
+
+			Expect(buffer.String()).To(ContainSubstring("Selected npm version (using package.json): 10.9.2"))
+			Expect(buffer.String()).To(ContainSubstring("Installing npm 10.9.2"))
+		})
+
+		context("when the application is launched with the slim runtime", func() {
+			it.Before(func() {
+				entryResolver.MergeLayerTypesCall.Returns.Launch = true
+				entryResolver.MergeLayerTypesCall.Returns.Build = true
+				t.Setenv("BP_NODE_RUNTIME_SLIM", "true")
+
+				dependencyManager.DeliverCall.Stub = func(_ postal.Dependency, _, layerPath, _ string) error {
+					Expect(os.MkdirAll(filepath.Join(layerPath, "bin"), os.ModePerm)).To(Succeed())
+					return os.WriteFile(filepath.Join(layerPath, "bin", "node"), nil, 0755)
+				}
+				dependencyManager.GenerateBillOfMaterialsCall.Stub = func(dependencies ...postal.Dependency) []packit.BOMEntry {
+					var entries []packit.BOMEntry
+					for _, dependency := range dependencies {
+						entries = append(entries, packit.BOMEntry{Name: dependency.Name})
+					}
+					return entries
+				}
+			})
+
+			it("keeps npm out of the launch image", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(3))
+				Expect(result.Layers[1].Name).To(Equal("node-runtime"))
+				Expect(result.Layers[2].Name).To(Equal("npm"))
+				Expect(result.Layers[2].Build).To(BeTrue())
+				Expect(result.Layers[2].Launch).To(BeFalse())
+
+				Expect(result.Build.BOM).To(Equal([]packit.BOMEntry{
+					{Name: "Node Engine"},
+					{Name: "npm"},
+				}))
+				Expect(result.Launch.BOM).To(Equal([]packit.BOMEntry{
+					{Name: "Node Engine"},
+				}))
+			})
+		})
+
+		context("when the npm layer already holds the requested version", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "npm.toml"), []byte("[metadata]\ndependency-sha = \"sha256:npm-sha\"\nbuild = false\nlaunch = false\n"), 0600)).To(Succeed())
+			})
+
+			it("reuses the npm layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(delivered).To(Equal([]string{
+					fmt.Sprintf("10.11.12 %s", filepath.Join(layersDir, "node")),
+				}))
+				Expect(result.Layers).To(HaveLen(2))
+				Expect(result.Layers[1].Name).To(Equal("npm"))
+
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Reusing cached layer %s", filepath.Join(layersDir, "npm"))))
+			})
+		})
+
+		context("when the buildpack.toml has no npm version that satisfies the request", func() {
+			it.Before(func() {
+				dependencyManager.ResolveCall.Stub = func(_, id, _, _ string) (postal.Dependency, error) {
+					if id == "npm" {
+						return postal.Dependency{}, errors.New("failed to satisfy \"npm\" dependency version constraint \"10.9.*\"")
+					}
+					return postal.Dependency{Name: "Node Engine", Version: "10.11.12"}, nil
+				}
+			})
+
+			it("falls back to the npm bundled with Node with a warning", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(1))
+				Expect(result.Layers[0].Name).To(Equal("node"))
+				Expect(dependencyManager.GenerateBillOfMaterialsCall.Receives.Dependencies).To(Equal([]postal.Dependency{
+					{Name: "Node Engine", Version: "10.11.12"},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring(`WARNING: npm version "10.9.*" requested by package.json is not available.`))
+				Expect(buffer.String()).To(ContainSubstring("Using the npm bundled with Node instead"))
+			})
+
+			context("when the version is requested by BP_NPM_VERSION", func() {
+				it.Before(func() {
+					buildContext.Plan.Entries[len(buildContext.Plan.Entries)-1].Metadata["version-source"] = "BP_NPM_VERSION"
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`failed to resolve npm version "10.9.*" requested by BP_NPM_VERSION: failed to satisfy "npm" dependency version constraint "10.9.*"`))
+				})
+			})
+		})
+	})
+
+	context("when the plan requests corepack", func() {
+		it.Before(func() {
+			buildContext.Plan.Entries = append(buildContext.Plan.Entries, packit.BuildpackPlanEntry{
+				Name: "corepack",
+				Metadata: map[string]interface{}{
+					"package-manager": "pnpm@9.12.0",
+				},
+			})
+
+			dependencyManager.DeliverCall.Stub = func(_ postal.Dependency, _, layerPath, _ string) error {
+				return os.MkdirAll(filepath.Join(layerPath, "lib", "node_modules", "corepack", "dist"), os.ModePerm)
+			}
+		})
+
+		it("links the corepack shims into a build layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(2))
+			layer := result.Layers[1]
+
+			Expect(layer.Name).To(Equal("corepack"))
+			Expect(layer.Build).To(BeTrue())
+			Expect(layer.Cache).To(BeTrue())
+			Expect(layer.Launch).To(BeFalse())
+			Expect(layer.BuildEnv).To(Equal(packit.Environment{
+				"COREPACK_HOME.override":                  filepath.Join(layersDir, "corepack", "home"),
+				"COREPACK_ENABLE_DOWNLOAD_PROMPT.default": "0",
+			}))
+
+			for _, shim := range []string{"pnpm", "pnpx", "yarn", "yarnpkg"} {
+				target, err := os.Readlink(filepath.Join(layersDir, "corepack", "bin", shim))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(target).To(Equal(filepath.Join(layersDir, "node", "lib", "node_modules", "corepack", "dist", fmt.Sprintf("%s.js", shim))))
+			}
+			Expect(filepath.Join(layersDir, "corepack", "home")).To(BeADirectory())
+
+			Expect(buffer.String()).To(ContainSubstring("Enabling corepack"))
+		})
+
+		context("when node is only required at launch", func() {
+			it.Before(func() {
+				entryResolver.MergeLayerTypesCall.Returns.Launch = true
+				t.Setenv("BP_NODE_RUNTIME_SLIM", "false")
+			})
+
+			it("makes the node layer available to the shims during the build", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(2))
+				Expect(result.Layers[0].Name).To(Equal("node"))
+				Expect(result.Layers[0].Build).To(BeTrue())
+				Expect(result.Layers[0].Cache).To(BeTrue())
+				Expect(result.Layers[0].Launch).To(BeTrue())
+				Expect(result.Layers[1].Name).To(Equal("corepack"))
+			})
+		})
+
+		context("when the node layer is reused", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "node.toml"), []byte("[metadata]\ndependency-sha = \"some-sha\"\nbuild = true\nlaunch = false\n"), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, "node", "lib", "node_modules", "corepack", "dist"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(layersDir, "corepack", "home", "v1"), os.ModePerm)).To(Succeed())
+
+				dependencyManager.ResolveCall.Returns.Dependency = postal.Dependency{
+					Name:     "Node Engine",
+					Version:  "10.11.12",
+					Checksum: "some-sha",
+				}
+			})
+
+			it("links the shims and keeps the corepack cache", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.DeliverCall.CallCount).To(Equal(0))
+				Expect(result.Layers).To(HaveLen(2))
+				Expect(result.Layers[1].Name).To(Equal("corepack"))
+
+				target, err := os.Readlink(filepath.Join(layersDir, "corepack", "bin", "pnpm"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(target).To(Equal(filepath.Join(layersDir, "node", "lib", "node_modules", "corepack", "dist", "pnpm.js")))
+				Expect(filepath.Join(layersDir, "corepack", "home", "v1")).To(BeADirectory())
+			})
+		})
+
+		context("when the installed Node does not bundle corepack", func() {
+			it.Before(func() {
+				dependencyManager.DeliverCall.Stub = nil
+			})
+
+			it("skips corepack with a warning", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(1))
+				Expect(result.Layers[0].Name).To(Equal("node"))
+				Expect(filepath.Join(layersDir, "corepack")).NotTo(BeADirectory())
+
+				Expect(buffer.String()).To(ContainSubstring("WARNING: Not enabling corepack, since it is not bundled with Node 10.11.12."))
+			})
+
+			context("when BP_NODE_ENABLE_COREPACK is true", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_ENABLE_COREPACK", "true")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError("failed to enable corepack: it is not bundled with Node 10.11.12: install a Node version that ships corepack or set BP_NODE_ENABLE_COREPACK=false"))
+				})
+			})
+		})
+	})
+
+	context("when BP_NODE_PIN_RESOLVED is set", func() {
+		it.Before(func() {
+			t.Setenv("BP_NODE_PIN_RESOLVED", "true")
+
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
+				Name: "node",
+				Metadata: map[string]interface{}{
+					"version":        "*",
+					"version-source": ".nvmrc",
+				},
+			}
+		})
+
+		it("records the resolved version in the layer metadata", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("*"))
+			Expect(result.Layers[0].Metadata[nodeengine.PinnedVersionKey]).To(Equal("10.11.12"))
+		})
+
+		context("when a previous build pinned a version", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(layersDir, "node.toml"), []byte("[metadata]\npinned-version = \"10.11.12\"\n"), 0600)).To(Succeed())
+			})
+
+			it("selects the pinned version again", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("10.11.12"))
+				Expect(result.Layers[0].Metadata[nodeengine.PinnedVersionKey]).To(Equal("10.11.12"))
+				Expect(buffer.String()).To(ContainSubstring("Keeping pinned version 10.11.12, set BP_NODE_UPGRADE=true to select a newer version"))
+			})
+
+			context("when BP_NODE_UPGRADE is set", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_UPGRADE", "true")
+					dependencyManager.ResolveCall.Returns.Dependency.Version = "12.13.14"
+				})
+
+				it("selects a new version and pins it", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("*"))
+					Expect(result.Layers[0].Metadata[nodeengine.PinnedVersionKey]).To(Equal("12.13.14"))
+					Expect(buffer.String()).To(ContainSubstring("Releasing pinned version 10.11.12, as requested by BP_NODE_UPGRADE"))
+				})
+			})
+
+			context("when the pinned version no longer satisfies the requested version", func() {
+				it.Before(func() {
+					entryResolver.ResolveCall.Returns.BuildpackPlanEntry.Metadata["version"] = "12.*"
+				})
+
+				it("selects a new version", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("12.*"))
+					Expect(buffer.String()).To(ContainSubstring(`Releasing pinned version 10.11.12, which does not satisfy "12.*"`))
+				})
+			})
+
+			context("when the pinned version is no longer shipped", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layersDir, "node.toml"), []byte("[metadata]\npinned-version = \"10.11.11\"\n"), 0600)).To(Succeed())
+				})
+
+				it("selects a new version", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("*"))
+					Expect(buffer.String()).To(ContainSubstring("Releasing pinned version 10.11.11, which is no longer shipped with the buildpack"))
+				})
+			})
+
+			context("when the pinned version is past its end of life", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "10.11.12"
+  stacks = ["some-stack"]
+  deprecation_date = 2021-04-30T00:00:00Z
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "12.13.14"
+  stacks = ["some-stack"]
+`), 0600)).To(Succeed())
+				})
+
+				it("selects a new version", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("*"))
+					Expect(buffer.String()).To(ContainSubstring("Releasing pinned version 10.11.12, which reached its end of life on 2021-04-30"))
+				})
+			})
+
+			context("when BP_NODE_PIN_RESOLVED is disabled", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_PIN_RESOLVED", "false")
+				})
+
+				it("ignores the pinned version and drops it", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dependencyManager.ResolveCall.Receives.Version).To(Equal("*"))
+					Expect(result.Layers[0].Metadata).NotTo(HaveKey(nodeengine.PinnedVersionKey))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when BP_NODE_PIN_RESOLVED is not a boolean", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_PIN_RESOLVED", "sometimes")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_PIN_RESOLVED value sometimes")))
+				})
+			})
+
+			context("when BP_NODE_UPGRADE is not a boolean", func() {
+				it.Before(func() {
+					t.Setenv("BP_NODE_UPGRADE", "soon")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_UPGRADE value soon")))
+				})
+			})
+		})
+	})
+
+	context("when BP_NODE_VERSION_RESOLUTION is set to an invalid value", func() {
+		it.Before(func() {
+			t.Setenv("BP_NODE_VERSION_RESOLUTION", "random")
+		})
+
+		it("returns an error", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(`invalid value for BP_NODE_VERSION_RESOLUTION: "random", expected "priority" or "intersect"`))
+		})
+	})
+
 	context("when nodejs has already been provided by an extension", func() {
 		it.Before(func() {
 			entryResolver.ResolveCall.Returns.BuildpackPlanEntry = packit.BuildpackPlanEntry{
 				Name: "",
 			}
+			entryResolver.ResolveCall.Returns.BuildpackPlanEntrySlice = nil
 		})
 
 		it("nodejs layer with environment variables is present", func() {
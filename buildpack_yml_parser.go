@@ -0,0 +1,76 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"gopkg.in/yaml.v3"
+)
+
+// BuildpackYMLParser reads the nodejs.version field of a buildpack.yml file.
+// Configuring the buildpack through buildpack.yml is deprecated in favor of
+// environment variables, but the version is still honored so that legacy
+// applications do not silently change versions.
+type BuildpackYMLParser struct {
+	aliasResolver AliasResolver
+}
+
+func NewBuildpackYMLParser(aliasResolver AliasResolver) BuildpackYMLParser {
+	return BuildpackYMLParser{
+		aliasResolver: aliasResolver,
+	}
+}
+
+func (p BuildpackYMLParser) ParseVersion(path string) (VersionResult, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return VersionResult{}, nil
+		}
+		return VersionResult{}, err
+	}
+
+	// The version is decoded as a node so that the line it was given on can be
+	// reported.
+	var buildpack struct {
+		NodeJS struct {
+			Version yaml.Node `yaml:"version"`
+		} `yaml:"nodejs"`
+	}
+
+	err = yaml.Unmarshal(content, &buildpack)
+	if err != nil {
+		return VersionResult{}, fmt.Errorf("failed to parse buildpack.yml: %w", err)
+	}
+
+	version := buildpack.NodeJS.Version
+	if version.Value == "" {
+		return VersionResult{}, nil
+	}
+
+	constraint, ok, err := p.aliasResolver.ResolveVersion(version.Value)
+	if err != nil {
+		return VersionResult{}, err
+	}
+
+	if !ok {
+		return VersionResult{}, fmt.Errorf("invalid version constraint specified in buildpack.yml nodejs.version: %q", version.Value)
+	}
+
+	return VersionResult{
+		Raw:        version.Value,
+		Constraint: constraint,
+		Path:       path,
+		Line:       version.Line,
+	}, nil
+}
+
+// warnBuildpackYMLDeprecation tells the user how to replace the given
+// buildpack.yml version with the equivalent BP_NODE_VERSION setting.
+func warnBuildpackYMLDeprecation(logger scribe.Emitter, version string) {
+	logger.Process("WARNING: Setting the Node version through buildpack.yml is deprecated and will be removed in a future release.")
+	logger.Subprocess("Set the equivalent environment variable instead, and remove nodejs.version from buildpack.yml:")
+	logger.Action("BP_NODE_VERSION=%q", version)
+	logger.Break()
+}
@@ -0,0 +1,102 @@
+package nodeengine_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBuildpackYMLParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser nodeengine.BuildpackYMLParser
+	)
+
+	it.Before(func() {
+		dir := t.TempDir()
+		path = filepath.Join(dir, "buildpack.yml")
+
+		buildpackTOMLPath := filepath.Join(dir, "buildpack.toml")
+		Expect(os.WriteFile(buildpackTOMLPath, []byte(`
+[metadata.lts-codenames]
+  iron = "20"
+  jod = "22"
+`), 0600)).To(Succeed())
+
+		parser = nodeengine.NewBuildpackYMLParser(nodeengine.NewAliasResolver(nodeengine.NewLTSCodenames(buildpackTOMLPath)))
+	})
+
+	it("returns a version constraint", func() {
+		testCases := map[string]string{
+			"nodejs:\n  version: 10.2.3\n":       "10.2.3",
+			"nodejs:\n  version: ~10\n":          "~10",
+			"nodejs:\n  version: \"v12.*\"\n":    "12.*",
+			"nodejs:\n  version: lts/iron\n":     "20.*",
+			"nodejs:\n  optimize-memory: true\n": "",
+			"npm:\n  version: 10.0.0\n":          "",
+			"":                                   "",
+			"nodejs:\n  version: 23.0.0-rc.1\n":  "23.0.0-rc.1",
+		}
+
+		for input, output := range testCases {
+			Expect(os.WriteFile(path, []byte(input), 0644)).To(Succeed())
+
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Constraint).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
+		}
+	})
+
+	it("reports where the version was found", func() {
+		Expect(os.WriteFile(path, []byte("---\nnodejs:\n  optimize-memory: true\n  version: lts/iron\n"), 0644)).To(Succeed())
+
+		result, err := parser.ParseVersion(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(nodeengine.VersionResult{
+			Raw:        "lts/iron",
+			Constraint: "20.*",
+			Path:       path,
+			Line:       4,
+		}))
+	})
+
+	context("when the buildpack.yml file does not exist", func() {
+		it("returns an empty result", func() {
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(nodeengine.VersionResult{}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the buildpack.yml is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte("nodejs: [%%%"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse buildpack.yml")))
+			})
+		})
+
+		context("when the version is not a valid version constraint", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte("nodejs:\n  version: not-a-version\n"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError(`invalid version constraint specified in buildpack.yml nodejs.version: "not-a-version"`))
+			})
+		})
+	})
+}
@@ -1,13 +1,25 @@
 package nodeengine
 
 const (
-	Node = "node"
-	Npm  = "npm"
+	Node        = "node"
+	NodeRuntime = "node-runtime"
+	Npm         = "npm"
+	Corepack    = "corepack"
 
 	DepKey             = "dependency-sha"
 	BuildKey           = "build"
 	LaunchKey          = "launch"
+	DecisionTraceKey   = "decision-trace"
+	FallbackKey        = "version-fallback"
+	PinnedVersionKey   = "pinned-version"
+	RuntimeSlimKey     = "runtime-slim"
 	NvmrcSource        = ".nvmrc"
 	BuildpackYMLSource = "buildpack.yml"
 	NodeVersionSource  = ".node-version"
+	PackageJSONSource  = "package.json"
+	VoltaSource        = "volta"
+	DevEnginesSource   = "devEngines"
+	ToolVersionsSource = ".tool-versions"
+	MiseSource         = "mise.toml"
+	DotMiseSource      = ".mise.toml"
 )
@@ -0,0 +1,139 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// corepackShims lists the package manager shims created by `corepack enable`.
+var corepackShims = []string{"pnpm", "pnpx", "yarn", "yarnpkg"}
+
+type CorepackPlanMetadata struct {
+	PackageManager string `toml:"package-manager,omitempty"`
+}
+
+// corepackEnabled reads whether corepack should be enabled from
+// BP_NODE_ENABLE_COREPACK. When it is not set, corepack is enabled for
+// projects that declare a pnpm or yarn packageManager field in their
+// package.json, which is also returned. An npm packageManager is left to the
+// npm bundled with Node, since corepack has no npm shim to enable.
+func corepackEnabled(workingDir, projectPath string) (bool, string, error) {
+	enableStr, set := os.LookupEnv("BP_NODE_ENABLE_COREPACK")
+	if set {
+		enable, err := strconv.ParseBool(enableStr)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to parse BP_NODE_ENABLE_COREPACK value %s: %w", enableStr, err)
+		}
+
+		if !enable {
+			return false, "", nil
+		}
+	}
+
+	path := filepath.Join(projectPath, PackageJSONSource)
+	err := checkVersionFile(Corepack, workingDir, path)
+	if err != nil {
+		return false, "", err
+	}
+
+	pkg, err := parsePackageJSON(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, "", err
+	}
+
+	auto := pkg.PackageManager != "" && !strings.HasPrefix(pkg.PackageManager, "npm@")
+
+	return set || auto, pkg.PackageManager, nil
+}
+
+// corepackRequired reports whether corepack was enabled explicitly with
+// BP_NODE_ENABLE_COREPACK, rather than detected from the packageManager field.
+// The value was already validated during detection.
+func corepackRequired() bool {
+	required, _ := strconv.ParseBool(os.Getenv("BP_NODE_ENABLE_COREPACK"))
+	return required
+}
+
+// corepackRequested reports whether the build plan has a corepack entry.
+func corepackRequested(entries []packit.BuildpackPlanEntry) bool {
+	for _, entry := range entries {
+		if entry.Name == Corepack {
+			return true
+		}
+	}
+	return false
+}
+
+// enableCorepack does what `corepack enable` does for the Node installation
+// of the given version at the given path: it links the pnpm and yarn shims into the bin directory
+// of the corepack layer, which is put on the PATH of later buildpacks. The
+// package managers that corepack downloads are kept in COREPACK_HOME, which
+// is cached across builds. Newer Node versions no longer bundle corepack: the
+// build then fails when corepack was enabled explicitly, and otherwise skips
+// it with a warning, in which case the boolean result is false.
+func enableCorepack(layers packit.Layers, nodePath, version string, logger scribe.Emitter) (packit.Layer, bool, error) {
+	corepackPath := filepath.Join(nodePath, "lib", "node_modules", "corepack")
+	exists, err := fs.Exists(filepath.Join(corepackPath, "dist"))
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	if !exists {
+		if corepackRequired() {
+			return packit.Layer{}, false, fmt.Errorf("failed to enable corepack: it is not bundled with Node %s: install a Node version that ships corepack or set BP_NODE_ENABLE_COREPACK=false", version)
+		}
+
+		logger.Process("WARNING: Not enabling corepack, since it is not bundled with Node %s.", version)
+		logger.Subprocess("Install the package manager named by the packageManager field of the package.json another way, or select a Node version that ships corepack.")
+		logger.Break()
+
+		return packit.Layer{}, false, nil
+	}
+
+	corepackLayer, err := layers.Get(Corepack)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	logger.Process("Enabling corepack")
+
+	binPath := filepath.Join(corepackLayer.Path, "bin")
+	err = os.RemoveAll(binPath)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	err = os.MkdirAll(binPath, os.ModePerm)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	for _, shim := range corepackShims {
+		err = os.Symlink(filepath.Join(corepackPath, "dist", fmt.Sprintf("%s.js", shim)), filepath.Join(binPath, shim))
+		if err != nil {
+			return packit.Layer{}, false, err
+		}
+	}
+
+	homePath := filepath.Join(corepackLayer.Path, "home")
+	err = os.MkdirAll(homePath, os.ModePerm)
+	if err != nil {
+		return packit.Layer{}, false, err
+	}
+
+	corepackLayer.Launch, corepackLayer.Build, corepackLayer.Cache = false, true, true
+	corepackLayer.BuildEnv.Override("COREPACK_HOME", homePath)
+	corepackLayer.BuildEnv.Default("COREPACK_ENABLE_DOWNLOAD_PROMPT", "0")
+
+	logger.Subprocess("Linked the %s shims into %s", strings.Join(corepackShims, ", "), binPath)
+	logger.EnvironmentVariables(corepackLayer)
+
+	return corepackLayer, true, nil
+}
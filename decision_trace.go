@@ -0,0 +1,138 @@
+package nodeengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// DecisionTrace records how the Node version installed into the node layer
+// was selected. It is printed at the DEBUG log level and persisted in the
+// layer metadata under DecisionTraceKey.
+type DecisionTrace struct {
+	Resolution string              `toml:"resolution"`
+	Candidates []DecisionCandidate `toml:"candidates"`
+	Selected   DecisionSelection   `toml:"selected"`
+}
+
+// DecisionCandidate is a single version requirement from the build plan.
+// Priority is the position of the source in the priority list, starting at
+// 1 for the highest priority source, or 0 when the source is not ranked.
+type DecisionCandidate struct {
+	Source    string   `toml:"source"`
+	Workspace string   `toml:"workspace,omitempty"`
+	Version   string   `toml:"version"`
+	Priority  int      `toml:"priority"`
+	Matches   []string `toml:"matches,omitempty"`
+	Selected  bool     `toml:"selected"`
+}
+
+type DecisionSelection struct {
+	Source          string `toml:"source"`
+	Version         string `toml:"version"`
+	Resolved        string `toml:"resolved"`
+	Deprecated      bool   `toml:"deprecated"`
+	DeprecationDate string `toml:"deprecation-date,omitempty"`
+}
+
+func newDecisionTrace(resolution string, entry packit.BuildpackPlanEntry, entries []packit.BuildpackPlanEntry, dependencies []postal.Dependency, dependency postal.Dependency, now time.Time) DecisionTrace {
+	if resolution == "" {
+		resolution = ResolutionPriority
+	}
+
+	selectedSource, _ := entry.Metadata["version-source"].(string)
+	selectedVersion, _ := entry.Metadata["version"].(string)
+
+	selectedSources := map[string]bool{}
+	for _, source := range strings.Split(selectedSource, ", ") {
+		selectedSources[source] = true
+	}
+
+	trace := DecisionTrace{
+		Resolution: resolution,
+		Selected: DecisionSelection{
+			Source:   selectedSource,
+			Version:  selectedVersion,
+			Resolved: dependency.Version,
+		},
+	}
+
+	if !dependency.DeprecationDate.IsZero() {
+		trace.Selected.Deprecated = !now.Before(dependency.DeprecationDate)
+		trace.Selected.DeprecationDate = dependency.DeprecationDate.Format(time.RFC3339)
+	}
+
+	for _, e := range entries {
+		source, _ := e.Metadata["version-source"].(string)
+		version, _ := e.Metadata["version"].(string)
+		workspace, _ := e.Metadata["workspace"].(string)
+
+		candidate := DecisionCandidate{
+			Source:    source,
+			Workspace: workspace,
+			Version:   version,
+			Priority:  sourcePriority(source),
+			Selected:  source != "" && selectedSources[planEntryLabel(e)],
+		}
+
+		if constraint, err := semver.NewConstraint(version); err == nil && version != "" {
+			for _, d := range dependencies {
+				if constraint.Check(semver.MustParse(d.Version)) {
+					candidate.Matches = append(candidate.Matches, d.Version)
+				}
+			}
+		}
+
+		trace.Candidates = append(trace.Candidates, candidate)
+	}
+
+	return trace
+}
+
+func (t DecisionTrace) Log(logger scribe.Emitter) {
+	logger.Debug.Process("Version selection (resolution: %s)", t.Resolution)
+	for _, candidate := range t.Candidates {
+		source := candidate.Source
+		if source == "" {
+			source = "<unknown>"
+		}
+
+		if candidate.Workspace != "" {
+			source = fmt.Sprintf("%s (%s)", source, candidate.Workspace)
+		}
+
+		priority := "unranked"
+		if candidate.Priority > 0 {
+			priority = "priority " + strconv.Itoa(candidate.Priority)
+		}
+
+		matches := "no matching versions"
+		if len(candidate.Matches) > 0 {
+			matches = "matches " + strings.Join(candidate.Matches, ", ")
+		}
+
+		marker := ""
+		if candidate.Selected {
+			marker = " [selected]"
+		}
+
+		logger.Debug.Subprocess("%s (%s): %q %s%s", source, priority, candidate.Version, matches, marker)
+	}
+
+	deprecation := "not deprecated"
+	if t.Selected.DeprecationDate != "" {
+		deprecation = "deprecation date " + t.Selected.DeprecationDate
+		if t.Selected.Deprecated {
+			deprecation = "deprecated since " + t.Selected.DeprecationDate
+		}
+	}
+
+	logger.Debug.Subprocess("Selected %s from %q (%s)", t.Selected.Resolved, t.Selected.Version, deprecation)
+	logger.Debug.Break()
+}
@@ -0,0 +1,187 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+// availableDependencies returns the node dependencies listed in the
+// buildpack.toml at the given path that can be installed on the given stack
+// and target platform, sorted from newest to oldest. The filtering mirrors
+// the one performed by postal.Service.Resolve.
+func availableDependencies(path, stack string) ([]postal.Dependency, error) {
+	var buildpack struct {
+		Metadata struct {
+			Dependencies []postal.Dependency `toml:"dependencies"`
+		} `toml:"metadata"`
+	}
+
+	_, err := toml.DecodeFile(path, &buildpack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse buildpack.toml: %w", err)
+	}
+
+	targetOS, targetArch := targetPlatform()
+
+	var dependencies []postal.Dependency
+	for _, dependency := range buildpack.Metadata.Dependencies {
+		if dependency.ID != Node || !supportsStack(dependency, stack) || !supportsTarget(dependency, targetOS, targetArch) {
+			continue
+		}
+
+		if _, err := semver.NewVersion(dependency.Version); err != nil {
+			return nil, fmt.Errorf("failed to parse version of dependency %q: %w", dependency.Version, err)
+		}
+
+		dependencies = append(dependencies, dependency)
+	}
+
+	sort.SliceStable(dependencies, func(i, j int) bool {
+		return semver.MustParse(dependencies[i].Version).GreaterThan(semver.MustParse(dependencies[j].Version))
+	})
+
+	return dependencies, nil
+}
+
+// targetPlatform returns the operating system and architecture that the
+// dependency is installed for.
+func targetPlatform() (string, string) {
+	targetOS := os.Getenv("CNB_TARGET_OS")
+	if targetOS == "" {
+		targetOS = runtime.GOOS
+	}
+
+	targetArch := os.Getenv("CNB_TARGET_ARCH")
+	if targetArch == "" {
+		targetArch = runtime.GOARCH
+	}
+
+	return targetOS, targetArch
+}
+
+func supportsStack(dependency postal.Dependency, stack string) bool {
+	for _, s := range dependency.Stacks {
+		if s == stack || s == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func supportsTarget(dependency postal.Dependency, targetOS, targetArch string) bool {
+	if dependency.OS == "" && dependency.Arch == "" {
+		return true
+	}
+
+	return dependency.OS == targetOS && dependency.Arch == targetArch
+}
+
+// NoMatchingVersionError is returned when none of the dependencies available
+// for the target platform satisfies the selected version requirement. It
+// suggests the release line that is nearest to the requirement.
+type NoMatchingVersionError struct {
+	Source    string
+	Version   string
+	Platform  string
+	Available []string
+
+	// NearestLine is the release line closest to the requirement, and
+	// NearestVersion the newest version available in it.
+	NearestLine    string
+	NearestVersion string
+}
+
+func (e NoMatchingVersionError) Error() string {
+	lines := []string{fmt.Sprintf("no version of node satisfies %q from %s", e.Version, e.Source)}
+	if len(e.Available) == 0 {
+		lines = append(lines, fmt.Sprintf("  no versions are available for %s", e.Platform))
+		return strings.Join(lines, "\n")
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("  available versions for %s: %s", e.Platform, strings.Join(e.Available, ", ")),
+		fmt.Sprintf("  nearest release line: %q (%s)", e.NearestLine, e.NearestVersion),
+	)
+
+	return strings.Join(lines, "\n")
+}
+
+var majorVersionPattern = regexp.MustCompile(`\d+`)
+
+// checkSatisfiable returns a NoMatchingVersionError when the version of the
+// given plan entry is a constraint that none of the dependencies satisfies.
+// Entries without a version are resolved to the default version, and
+// malformed constraints are left for the dependency manager to report.
+func checkSatisfiable(entry packit.BuildpackPlanEntry, dependencies []postal.Dependency) error {
+	version, _ := entry.Metadata["version"].(string)
+	if version == "" || version == "default" {
+		return nil
+	}
+
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return nil
+	}
+
+	targetOS, targetArch := targetPlatform()
+	noMatchErr := NoMatchingVersionError{
+		Source:   planEntryLabel(entry),
+		Version:  version,
+		Platform: fmt.Sprintf("%s/%s", targetOS, targetArch),
+	}
+
+	for _, dependency := range dependencies {
+		if constraint.Check(semver.MustParse(dependency.Version)) {
+			return nil
+		}
+
+		noMatchErr.Available = append(noMatchErr.Available, dependency.Version)
+	}
+
+	if len(dependencies) > 0 {
+		noMatchErr.NearestLine, noMatchErr.NearestVersion = nearestReleaseLine(version, dependencies)
+	}
+
+	return noMatchErr
+}
+
+// nearestReleaseLine returns the major release line of the dependencies that
+// is nearest to the first major version named in the given constraint,
+// preferring the newer line on a tie, along with the newest version in that
+// line. The dependencies are expected to be sorted from newest to oldest, and
+// the newest line is returned when the constraint names no version.
+func nearestReleaseLine(version string, dependencies []postal.Dependency) (string, string) {
+	nearest := dependencies[0]
+
+	if match := majorVersionPattern.FindString(version); match != "" {
+		requested, err := strconv.ParseUint(match, 10, 64)
+		if err == nil {
+			distance := func(d postal.Dependency) uint64 {
+				major := semver.MustParse(d.Version).Major()
+				if major > requested {
+					return major - requested
+				}
+				return requested - major
+			}
+
+			for _, dependency := range dependencies {
+				if distance(dependency) < distance(nearest) {
+					nearest = dependency
+				}
+			}
+		}
+	}
+
+	return fmt.Sprintf("%d.*", semver.MustParse(nearest.Version).Major()), nearest.Version
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/Masterminds/semver/v3"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	"github.com/paketo-buildpacks/libdependency/retrieve"
 	"github.com/paketo-buildpacks/libdependency/upstream"
 	"github.com/paketo-buildpacks/libdependency/versionology"
@@ -17,8 +24,10 @@ import (
 )
 
 type NodeRelease struct {
-	Version string `json:"version"`
-	Date    string `json:"date"`
+	Version  string      `json:"version"`
+	Date     string      `json:"date"`
+	LTS      interface{} `json:"lts"`
+	Security bool        `json:"security"`
 }
 
 type ReleaseSchedule map[string]struct {
@@ -33,22 +42,188 @@ func (nodeMetadata NodeMetadata) Version() *semver.Version {
 	return nodeMetadata.SemverVersion
 }
 
+const (
+	distURL = "https://nodejs.org/dist"
+	rcURL   = "https://nodejs.org/download/rc"
+)
+
+// includeRC adds the release candidates published in the nodejs.org rc
+// channel to the versions that are retrieved.
+var includeRC bool
+
+// releaseKeysPath is the path of the armored keyring holding the public keys
+// of the Node.js release team, which the SHASUMS256.txt files of releases
+// have to be signed with.
+var releaseKeysPath string
+
 func main() {
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "lts-codenames":
+			err = updateBuildpackMetadata("lts-codenames", os.Args[2:], func(nodeReleases []NodeRelease, _ cargo.Config) interface{} {
+				return getLTSCodenames(nodeReleases)
+			})
+		case "security-releases":
+			err = updateSecurityReleases(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	flag.BoolVar(&includeRC, "include-rc", false, "also retrieve release candidates from the nodejs.org rc channel")
+	flag.StringVar(&releaseKeysPath, "release-keys-path", "", "full path to the armored keyring of the Node.js release team")
 	retrieve.NewMetadataWithPlatforms("node", getAllVersions, generateMetadataWithPlatform)
 }
 
-func generateMetadataWithPlatform(versionFetcher versionology.VersionFetcher, platform retrieve.Platform) ([]versionology.Dependency, error) {
-	version := versionFetcher.Version().String()
+// updateBuildpackMetadata sets the given key of the [metadata] table of the
+// buildpack.toml to the value derived from the nodejs.org release index, so
+// that the buildpack can use release information that the dependency entries
+// cannot carry. The "lts-codenames" key lets the buildpack resolve aliases
+// like "lts/jod" without code changes.
+func updateBuildpackMetadata(key string, args []string, value func([]NodeRelease, cargo.Config) interface{}) error {
+	buildpackTomlPath, err := parseBuildpackTomlPath(key, args)
+	if err != nil {
+		return err
+	}
 
-	body, err := httpGet("https://nodejs.org/dist/index.json")
+	nodeReleases, err := getReleaseIndex(distURL)
 	if err != nil {
-		return nil, fmt.Errorf("could not get release index: %w", err)
+		return err
 	}
 
-	var nodeReleases []NodeRelease
-	err = json.Unmarshal(body, &nodeReleases)
+	content, err := os.ReadFile(buildpackTomlPath)
 	if err != nil {
-		return nil, fmt.Errorf("could not unmarshal response: %w\n%s", err, body)
+		return fmt.Errorf("could not read buildpack.toml: %w", err)
+	}
+
+	var config cargo.Config
+	err = cargo.DecodeConfig(bytes.NewReader(content), &config)
+	if err != nil {
+		return fmt.Errorf("could not decode buildpack.toml: %w", err)
+	}
+
+	if config.Metadata.Unstructured == nil {
+		config.Metadata.Unstructured = map[string]interface{}{}
+	}
+	config.Metadata.Unstructured[key] = value(nodeReleases, config)
+
+	file, err := os.Create(buildpackTomlPath)
+	if err != nil {
+		return fmt.Errorf("could not write buildpack.toml: %w", err)
+	}
+	defer file.Close()
+
+	err = cargo.EncodeConfig(file, config)
+	if err != nil {
+		return fmt.Errorf("could not encode buildpack.toml: %w", err)
+	}
+
+	return nil
+}
+
+// getLTSCodenames maps the lowercased codename of each LTS release line to
+// its major version. The "lts" field of a release in the index is false until
+// its release line enters long-term support, and the codename afterwards.
+func getLTSCodenames(nodeReleases []NodeRelease) map[string]interface{} {
+	codenames := map[string]interface{}{}
+	for _, release := range nodeReleases {
+		codename, ok := release.LTS.(string)
+		if !ok || codename == "" {
+			continue
+		}
+
+		major := strings.Split(strings.TrimPrefix(release.Version, "v"), ".")[0]
+		codenames[strings.ToLower(codename)] = major
+	}
+
+	return codenames
+}
+
+// updateSecurityReleases sets the security field of the node dependencies
+// of the buildpack.toml whose version the nodejs.org release index marks as a
+// security release, and removes it from the others, so that the buildpack can
+// flag versions that miss a security fix. The entries of cargo.Config cannot
+// carry the field, so the buildpack.toml is edited as a plain TOML document.
+func updateSecurityReleases(args []string) error {
+	buildpackTomlPath, err := parseBuildpackTomlPath("security-releases", args)
+	if err != nil {
+		return err
+	}
+
+	nodeReleases, err := getReleaseIndex(distURL)
+	if err != nil {
+		return err
+	}
+
+	security := map[string]bool{}
+	for _, release := range nodeReleases {
+		if release.Security {
+			security[strings.TrimPrefix(release.Version, "v")] = true
+		}
+	}
+
+	var buildpack map[string]interface{}
+	_, err = toml.DecodeFile(buildpackTomlPath, &buildpack)
+	if err != nil {
+		return fmt.Errorf("could not decode buildpack.toml: %w", err)
+	}
+
+	metadata, _ := buildpack["metadata"].(map[string]interface{})
+	dependencies, _ := metadata["dependencies"].([]map[string]interface{})
+	for _, dependency := range dependencies {
+		version, _ := dependency["version"].(string)
+		if dependency["id"] == "node" && security[version] {
+			dependency["security"] = true
+		} else {
+			delete(dependency, "security")
+		}
+	}
+
+	file, err := os.Create(buildpackTomlPath)
+	if err != nil {
+		return fmt.Errorf("could not write buildpack.toml: %w", err)
+	}
+	defer file.Close()
+
+	err = toml.NewEncoder(file).Encode(buildpack)
+	if err != nil {
+		return fmt.Errorf("could not encode buildpack.toml: %w", err)
+	}
+
+	return nil
+}
+
+// parseBuildpackTomlPath returns the path given by the buildpack.toml path
+// flag of the named subcommand.
+func parseBuildpackTomlPath(name string, args []string) (string, error) {
+	var buildpackTomlPath string
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&buildpackTomlPath, "buildpackTomlPath", "", "full path to the buildpack.toml file")
+	flags.StringVar(&buildpackTomlPath, "buildpack_toml_path", buildpackTomlPath, "full path to the buildpack.toml file")
+	flags.StringVar(&buildpackTomlPath, "buildpack-toml-path", buildpackTomlPath, "full path to the buildpack.toml file")
+	err := flags.Parse(args)
+	if err != nil {
+		return "", err
+	}
+
+	if buildpackTomlPath == "" {
+		return "", fmt.Errorf("missing required flag --buildpack_toml_path")
+	}
+
+	return buildpackTomlPath, nil
+}
+
+func generateMetadataWithPlatform(versionFetcher versionology.VersionFetcher, platform retrieve.Platform) ([]versionology.Dependency, error) {
+	version := versionFetcher.Version().String()
+
+	nodeReleases, err := getNodeReleases()
+	if err != nil {
+		return nil, err
 	}
 
 	releaseSchedule, err := getReleaseSchedule()
@@ -66,15 +241,9 @@ func generateMetadataWithPlatform(versionFetcher versionology.VersionFetcher, pl
 }
 
 func getAllVersions() (versionology.VersionFetcherArray, error) {
-	body, err := httpGet("https://nodejs.org/dist/index.json")
+	nodeReleases, err := getNodeReleases()
 	if err != nil {
-		return nil, fmt.Errorf("could not get release index: %w", err)
-	}
-
-	var nodeReleases []NodeRelease
-	err = json.Unmarshal(body, &nodeReleases)
-	if err != nil {
-		return nil, fmt.Errorf("could not unmarshal response: %w\n%s", err, body)
+		return nil, err
 	}
 
 	sort.SliceStable(nodeReleases, func(i, j int) bool {
@@ -91,6 +260,52 @@ func getAllVersions() (versionology.VersionFetcherArray, error) {
 	return versions, nil
 }
 
+// getNodeReleases returns the releases listed in the index of the nodejs.org
+// dist channel, along with those of the rc channel when includeRC is set.
+func getNodeReleases() ([]NodeRelease, error) {
+	nodeReleases, err := getReleaseIndex(distURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if includeRC {
+		rcReleases, err := getReleaseIndex(rcURL)
+		if err != nil {
+			return nil, err
+		}
+
+		nodeReleases = append(nodeReleases, rcReleases...)
+	}
+
+	return nodeReleases, nil
+}
+
+func getReleaseIndex(channelURL string) ([]NodeRelease, error) {
+	body, err := httpGet(fmt.Sprintf("%s/index.json", channelURL))
+	if err != nil {
+		return nil, fmt.Errorf("could not get release index: %w", err)
+	}
+
+	var nodeReleases []NodeRelease
+	err = json.Unmarshal(body, &nodeReleases)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal response: %w\n%s", err, body)
+	}
+
+	return nodeReleases, nil
+}
+
+// releaseChannelURL returns the URL of the nodejs.org channel that publishes
+// the given version. Release candidates, like v23.0.0-rc.1, are published in
+// the rc channel, which has the same layout as the dist channel.
+func releaseChannelURL(version string) string {
+	if strings.Contains(version, "-rc.") {
+		return rcURL
+	}
+
+	return distURL
+}
+
 func getReleaseSchedule() (ReleaseSchedule, error) {
 	body, err := httpGet("https://raw.githubusercontent.com/nodejs/Release/master/schedule.json")
 	if err != nil {
@@ -118,7 +333,7 @@ func createDependencyMetadata(release NodeRelease, releaseSchedule ReleaseSchedu
 	}
 
 	version := release.Version
-	url := fmt.Sprintf("https://nodejs.org/dist/%[1]s/node-%[1]s-%[2]s-%[3]s.tar.xz", version, platform.OS, nodeArch)
+	url := fmt.Sprintf("%[1]s/%[2]s/node-%[2]s-%[3]s-%[4]s.tar.xz", releaseChannelURL(version), version, platform.OS, nodeArch)
 
 	checksum, err := getChecksum(version, platform)
 	if err != nil {
@@ -180,9 +395,9 @@ func getChecksum(version string, platform retrieve.Platform) (string, error) {
 		nodeArch = platform.Arch
 	}
 
-	body, err := httpGet(fmt.Sprintf("https://nodejs.org/dist/%s/SHASUMS256.txt", version))
+	body, err := getVerifiedSHASUMS(fmt.Sprintf("%s/%s/SHASUMS256.txt", releaseChannelURL(version), version))
 	if err != nil {
-		return "", fmt.Errorf("could not get SHA256 file: %w", err)
+		return "", err
 	}
 
 	var dependencySHA string
@@ -197,6 +412,102 @@ func getChecksum(version string, platform retrieve.Platform) (string, error) {
 	return dependencySHA, nil
 }
 
+// getVerifiedSHASUMS fetches the SHASUMS256.txt file at the given URL and
+// verifies it against the binary signature published next to it with a .sig
+// extension, or else against the clearsigned copy with a .asc extension, whose
+// signed content is returned.
+func getVerifiedSHASUMS(url string) ([]byte, error) {
+	keyring, err := getReleaseKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := httpGet(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not get SHA256 file: %w", err)
+	}
+
+	signature, status, err := httpGetWithStatus(url + ".sig")
+	if err != nil {
+		return nil, fmt.Errorf("could not get SHA256 file signature: %w", err)
+	}
+
+	if status == http.StatusOK {
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(body), bytes.NewReader(signature), nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature %s.sig: %w", url, err)
+		}
+
+		return body, nil
+	}
+
+	clearsigned, status, err := httpGetWithStatus(url + ".asc")
+	if err != nil {
+		return nil, fmt.Errorf("could not get SHA256 file signature: %w", err)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("no signature found for %[1]s: expected %[1]s.sig or %[1]s.asc", url)
+	}
+
+	block, _ := clearsign.Decode(clearsigned)
+	if block == nil {
+		return nil, fmt.Errorf("invalid signature %s.asc: not a clearsigned message", url)
+	}
+
+	_, err = block.VerifySignature(keyring, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature %s.asc: %w", url, err)
+	}
+
+	return block.Plaintext, nil
+}
+
+var (
+	releaseKeys     openpgp.EntityList
+	releaseKeysErr  error
+	releaseKeysOnce sync.Once
+)
+
+func getReleaseKeys() (openpgp.EntityList, error) {
+	releaseKeysOnce.Do(func() {
+		if releaseKeysPath == "" {
+			releaseKeysErr = fmt.Errorf("missing required flag --release-keys-path")
+			return
+		}
+
+		file, err := os.Open(releaseKeysPath)
+		if err != nil {
+			releaseKeysErr = fmt.Errorf("could not read release keys: %w", err)
+			return
+		}
+		defer file.Close()
+
+		releaseKeys, err = openpgp.ReadArmoredKeyRing(file)
+		if err != nil {
+			releaseKeysErr = fmt.Errorf("could not read release keys: %w", err)
+		}
+	})
+
+	return releaseKeys, releaseKeysErr
+}
+
+func httpGetWithStatus(url string) ([]byte, int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not make get request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not read response: %w", err)
+	}
+
+	return body, resp.StatusCode, nil
+}
+
 func httpGet(url string) ([]byte, error) {
 	resp, err := http.Get(url)
 	if err != nil {
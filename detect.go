@@ -1,24 +1,46 @@
 package nodeengine
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
 
 //go:generate faux --interface VersionParser --output fakes/version_parser.go
 type VersionParser interface {
-	ParseVersion(path string) (version string, err error)
+	ParseVersion(path string) (result VersionResult, err error)
+}
+
+//go:generate faux --interface ToolchainParser --output fakes/toolchain_parser.go
+type ToolchainParser interface {
+	ParseToolchain(workingDir, path string) (toolchain Toolchain, err error)
+}
+
+//go:generate faux --interface RuntimeParser --output fakes/runtime_parser.go
+type RuntimeParser interface {
+	ParseRuntime(path string) (runtime Runtime, err error)
+}
+
+//go:generate faux --interface VersionResolver --output fakes/version_resolver.go
+type VersionResolver interface {
+	ResolveVersion(version string) (constraint string, ok bool, err error)
 }
 
 type BuildPlanMetadata struct {
 	Version       string `toml:"version"`
 	VersionSource string `toml:"version-source"`
+	Npm           string `toml:"npm,omitempty"`
+	Yarn          string `toml:"yarn,omitempty"`
+	OnFail        string `toml:"on-fail,omitempty"`
+	Workspace     string `toml:"workspace,omitempty"`
 }
 
-func Detect(nvmrcParser, nodeVersionParser VersionParser) packit.DetectFunc {
+func Detect(registry VersionSourceRegistry, logger scribe.Emitter) packit.DetectFunc {
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
 		var requirements []packit.BuildPlanRequirement
 
@@ -28,6 +50,17 @@ func Detect(nvmrcParser, nodeVersionParser VersionParser) packit.DetectFunc {
 		if customProjPath != "" {
 			customProjPath = filepath.Clean(customProjPath)
 			projectPath = filepath.Join(projectPath, customProjPath)
+
+			within, err := resolvesWithin(context.WorkingDir, projectPath)
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			if !within {
+				return packit.DetectResult{},
+					packit.Fail.WithMessage("expected value derived from BP_NODE_PROJECT_PATH [%s] to be inside the application directory [%s]", projectPath, context.WorkingDir)
+			}
+
 			exists, err := fs.Exists(projectPath)
 			if err != nil {
 				return packit.DetectResult{}, err
@@ -39,59 +72,147 @@ func Detect(nvmrcParser, nodeVersionParser VersionParser) packit.DetectFunc {
 			}
 		}
 
-		version, err := nvmrcParser.ParseVersion(filepath.Join(projectPath, NvmrcSource))
+		sources, err := registry.Enabled(os.Getenv("BP_NODE_VERSION_SOURCES"))
 		if err != nil {
 			return packit.DetectResult{}, err
 		}
 
-		if version != "" {
-			requirements = append(requirements, packit.BuildPlanRequirement{
-				Name: Node,
-				Metadata: BuildPlanMetadata{
-					Version:       version,
-					VersionSource: NvmrcSource,
-				},
-			})
+		workspaces, err := findWorkspaces(context.WorkingDir, projectPath)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		for _, workspace := range workspaces {
+			var (
+				pins       VersionResult
+				pinSource  string
+				firstIndex = len(requirements)
+			)
+
+			for _, source := range sources {
+				result, found, err := source.Find(context.WorkingDir, filepath.Join(projectPath, workspace))
+				if err != nil {
+					return packit.DetectResult{}, err
+				}
+
+				if !found && (result.Npm != "" || result.Yarn != "") && pinSource == "" {
+					pins, pinSource = result, source.Name()
+				}
+
+				// Sources that are not read from a file, like environment variables,
+				// apply to the project as a whole and are only recorded once.
+				if !found || (workspace != RootWorkspace && result.Path == "") {
+					continue
+				}
+
+				logger.Debug.Subprocess("Found Node version %q in %s", result.Raw, describeVersionSource(source.Name(), result))
+
+				if source.Name() == BuildpackYMLSource {
+					warnBuildpackYMLDeprecation(logger, result.Constraint)
+				}
+
+				metadata := BuildPlanMetadata{
+					Version:       result.Constraint,
+					VersionSource: source.Name(),
+					Npm:           result.Npm,
+					Yarn:          result.Yarn,
+					OnFail:        result.OnFail,
+				}
+
+				if len(workspaces) > 1 {
+					metadata.Workspace = workspace
+				}
+
+				requirements = append(requirements, packit.BuildPlanRequirement{
+					Name:     Node,
+					Metadata: metadata,
+				})
+			}
+
+			if pinSource != "" {
+				requirements = attachPackageManagerPins(requirements, firstIndex, pins, pinSource, workspace, len(workspaces) > 1, logger)
+			}
 		}
 
-		version = os.Getenv("BP_NODE_VERSION")
-		if version != "" {
+		if len(workspaces) > 1 {
+			logger.Debug.Subprocess("Checking that the version requirements of workspaces %s are compatible", strings.Join(workspaces, ", "))
+
+			dependencies, err := availableDependencies(filepath.Join(context.CNBPath, "buildpack.toml"), context.Stack)
+			if err != nil {
+				return packit.DetectResult{}, err
+			}
+
+			var entries []packit.BuildpackPlanEntry
+			for _, requirement := range requirements {
+				metadata := requirement.Metadata.(BuildPlanMetadata)
+				entries = append(entries, packit.BuildpackPlanEntry{
+					Name: requirement.Name,
+					Metadata: map[string]interface{}{
+						"version":        metadata.Version,
+						"version-source": metadata.VersionSource,
+						"on-fail":        metadata.OnFail,
+						"workspace":      metadata.Workspace,
+					},
+				})
+			}
+
+			_, err = intersectWorkspaces(entries, dependencies)
+			if err != nil {
+				return packit.DetectResult{}, fmt.Errorf("workspaces have incompatible Node version requirements: %w", err)
+			}
+		}
+
+		provisions := []packit.BuildPlanProvision{{Name: Node}}
+
+		corepack, packageManager, err := corepackEnabled(context.WorkingDir, projectPath)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		// The buildpack requires corepack itself so that the shims are created
+		// whether or not a later buildpack requires it.
+		if corepack {
+			if packageManager != "" {
+				logger.Debug.Subprocess("Enabling corepack for package manager %q from package.json", packageManager)
+			} else {
+				logger.Debug.Subprocess("Enabling corepack as requested by BP_NODE_ENABLE_COREPACK")
+			}
+
+			provisions = append(provisions, packit.BuildPlanProvision{Name: Corepack})
 			requirements = append(requirements, packit.BuildPlanRequirement{
-				Name: Node,
-				Metadata: BuildPlanMetadata{
-					Version:       version,
-					VersionSource: "BP_NODE_VERSION",
-				},
+				Name:     Corepack,
+				Metadata: CorepackPlanMetadata{PackageManager: packageManager},
 			})
 		}
 
-		version, err = nodeVersionParser.ParseVersion(filepath.Join(projectPath, NodeVersionSource))
+		npm, pinned, err := npmVersionRequirement(context.WorkingDir, projectPath, logger)
 		if err != nil {
 			return packit.DetectResult{}, err
 		}
 
-		if version != "" {
-			requirements = append(requirements, packit.BuildPlanRequirement{
-				Name: Node,
-				Metadata: BuildPlanMetadata{
-					Version:       version,
-					VersionSource: NodeVersionSource,
+		// A pinned npm is installed by the buildpack itself, so npm is always
+		// provided. Otherwise, npm is only provided as the one bundled with Node.
+		if pinned {
+			logger.Debug.Subprocess("Found npm version %q in %s", npm.Version, npm.VersionSource)
+
+			return packit.DetectResult{
+				Plan: packit.BuildPlan{
+					Provides: append(provisions, packit.BuildPlanProvision{Name: Npm}),
+					Requires: append(requirements, packit.BuildPlanRequirement{
+						Name:     Npm,
+						Metadata: npm,
+					}),
 				},
-			})
+			}, nil
 		}
 
 		return packit.DetectResult{
 			Plan: packit.BuildPlan{
-				Provides: []packit.BuildPlanProvision{
-					{Name: Node},
-				},
+				Provides: provisions,
 				Requires: requirements,
 				Or: []packit.BuildPlan{
 					{
-						Provides: []packit.BuildPlanProvision{
-							{Name: Node},
-							{Name: Npm},
-						},
+						Provides: append(provisions, packit.BuildPlanProvision{Name: Npm}),
 						Requires: requirements,
 					},
 				},
@@ -99,3 +220,53 @@ func Detect(nvmrcParser, nodeVersionParser VersionParser) packit.DetectFunc {
 		}, nil
 	}
 }
+
+// attachPackageManagerPins adds the npm and Yarn versions pinned by a source
+// that does not request a Node version to the requirements of the workspace,
+// starting at the given index, so that they are carried by whichever
+// requirement the build selects without competing with them on priority.
+// When no other source requests a Node version, the pins are carried by a
+// requirement without a version.
+func attachPackageManagerPins(requirements []packit.BuildPlanRequirement, first int, pins VersionResult, source, workspace string, multiple bool, logger scribe.Emitter) []packit.BuildPlanRequirement {
+	logger.Debug.Subprocess("Found package manager versions without a Node version in %s", describeVersionSource(source, pins))
+
+	if first == len(requirements) {
+		metadata := BuildPlanMetadata{
+			VersionSource: source,
+			Npm:           pins.Npm,
+			Yarn:          pins.Yarn,
+		}
+
+		if multiple {
+			metadata.Workspace = workspace
+		}
+
+		return append(requirements, packit.BuildPlanRequirement{
+			Name:     Node,
+			Metadata: metadata,
+		})
+	}
+
+	for i := first; i < len(requirements); i++ {
+		metadata := requirements[i].Metadata.(BuildPlanMetadata)
+		if metadata.Npm == "" && metadata.Yarn == "" {
+			metadata.Npm, metadata.Yarn = pins.Npm, pins.Yarn
+		}
+		requirements[i].Metadata = metadata
+	}
+
+	return requirements
+}
+
+// describeVersionSource names the location of a version requirement for log
+// output, including the file and line when they are known.
+func describeVersionSource(name string, result VersionResult) string {
+	switch {
+	case result.Path != "" && result.Line > 0:
+		return fmt.Sprintf("%s (%s:%d)", name, result.Path, result.Line)
+	case result.Path != "":
+		return fmt.Sprintf("%s (%s)", name, result.Path)
+	default:
+		return name
+	}
+}
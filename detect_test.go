@@ -1,6 +1,7 @@
 package nodeengine_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -10,6 +11,7 @@ import (
 	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
 	"github.com/paketo-buildpacks/node-engine/v5/fakes"
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
@@ -19,16 +21,49 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 	var (
 		Expect = NewWithT(t).Expect
 
-		nvmrcParser       *fakes.VersionParser
-		nodeVersionParser *fakes.VersionParser
-		detect            packit.DetectFunc
+		nvmrcParser        *fakes.VersionParser
+		buildpackYMLParser *fakes.VersionParser
+		nodeVersionParser  *fakes.VersionParser
+		packageJSONParser  *fakes.VersionParser
+		toolVersionsParser *fakes.VersionParser
+		miseParser         *fakes.VersionParser
+		voltaParser        *fakes.ToolchainParser
+		runtimeParser      *fakes.RuntimeParser
+		versionResolver    *fakes.VersionResolver
+		buffer             *bytes.Buffer
+		detect             packit.DetectFunc
 	)
 
 	it.Before(func() {
 		nvmrcParser = &fakes.VersionParser{}
+		buildpackYMLParser = &fakes.VersionParser{}
 		nodeVersionParser = &fakes.VersionParser{}
+		packageJSONParser = &fakes.VersionParser{}
+		toolVersionsParser = &fakes.VersionParser{}
+		miseParser = &fakes.VersionParser{}
+		voltaParser = &fakes.ToolchainParser{}
+		runtimeParser = &fakes.RuntimeParser{}
+		versionResolver = &fakes.VersionResolver{}
+		versionResolver.ResolveVersionCall.Stub = func(version string) (string, bool, error) {
+			return version, true, nil
+		}
 
-		detect = nodeengine.Detect(nvmrcParser, nodeVersionParser)
+		buffer = bytes.NewBuffer(nil)
+
+		registry := nodeengine.NewVersionSourceRegistry(
+			nodeengine.NewFileVersionSource(nodeengine.NvmrcSource, ".nvmrc", nvmrcParser),
+			nodeengine.NewFileVersionSource(nodeengine.BuildpackYMLSource, "buildpack.yml", buildpackYMLParser),
+			nodeengine.NewEnvironmentVersionSource("BP_NODE_VERSION", versionResolver),
+			nodeengine.NewFileVersionSource(nodeengine.NodeVersionSource, ".node-version", nodeVersionParser),
+			nodeengine.NewFileVersionSource(nodeengine.PackageJSONSource, "package.json", packageJSONParser),
+			nodeengine.NewVoltaVersionSource(voltaParser),
+			nodeengine.NewDevEnginesVersionSource(runtimeParser),
+			nodeengine.NewFileVersionSource(nodeengine.ToolVersionsSource, ".tool-versions", toolVersionsParser),
+			nodeengine.NewFileVersionSource(nodeengine.MiseSource, "mise.toml", miseParser),
+			nodeengine.NewFileVersionSource(nodeengine.DotMiseSource, ".mise.toml", miseParser),
+		)
+
+		detect = nodeengine.Detect(registry, scribe.NewEmitter(buffer).WithLevel("DEBUG"))
 	})
 
 	it("returns a plan that provides node", func() {
@@ -53,7 +88,12 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 
 	context("when the source code contains an .nvmrc file", func() {
 		it.Before(func() {
-			nvmrcParser.ParseVersionCall.Returns.Version = "1.2.3"
+			nvmrcParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{
+				Raw:        "v1.2.3",
+				Constraint: "1.2.3",
+				Path:       "/working-dir/.nvmrc",
+				Line:       2,
+			}
 		})
 
 		it("returns a plan that provides and requires that version of node", func() {
@@ -94,6 +134,35 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			}))
 
 			Expect(nvmrcParser.ParseVersionCall.Receives.Path).To(Equal("/working-dir/.nvmrc"))
+
+			Expect(buffer.String()).To(ContainSubstring(`Found Node version "v1.2.3" in .nvmrc (/working-dir/.nvmrc:2)`))
+		})
+	})
+
+	context("when the source code contains a buildpack.yml file", func() {
+		it.Before(func() {
+			buildpackYMLParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: "1.2.3"}
+		})
+
+		it("returns a plan that requires that version of node and warns about the deprecation", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: "/working-dir",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "1.2.3",
+						VersionSource: "buildpack.yml",
+					},
+				},
+			}))
+
+			Expect(buildpackYMLParser.ParseVersionCall.Receives.Path).To(Equal("/working-dir/buildpack.yml"))
+
+			Expect(buffer.String()).To(ContainSubstring("WARNING: Setting the Node version through buildpack.yml is deprecated and will be removed in a future release."))
+			Expect(buffer.String()).To(ContainSubstring(`BP_NODE_VERSION="1.2.3"`))
 		})
 	})
 
@@ -142,12 +211,41 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 					},
 				},
 			}))
+
+			Expect(versionResolver.ResolveVersionCall.Receives.Version).To(Equal("4.5.6"))
+		})
+
+		context("when $BP_NODE_VERSION is an alias", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_VERSION", "lts")).To(Succeed())
+				versionResolver.ResolveVersionCall.Stub = nil
+				versionResolver.ResolveVersionCall.Returns.Constraint = "22.*"
+				versionResolver.ResolveVersionCall.Returns.Ok = true
+			})
+
+			it("requires the version the alias resolves to", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name: nodeengine.Node,
+						Metadata: nodeengine.BuildPlanMetadata{
+							Version:       "22.*",
+							VersionSource: "BP_NODE_VERSION",
+						},
+					},
+				}))
+
+				Expect(versionResolver.ResolveVersionCall.Receives.Version).To(Equal("lts"))
+			})
 		})
 	})
 
 	context("when the source code contains a .node-version file", func() {
 		it.Before(func() {
-			nodeVersionParser.ParseVersionCall.Returns.Version = "7.8.9"
+			nodeVersionParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: "7.8.9"}
 		})
 
 		it("returns a plan that provides and requires that version of node", func() {
@@ -191,10 +289,249 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when the source code contains a package.json file with engines.node", func() {
+		it.Before(func() {
+			packageJSONParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: ">=18 <21"}
+		})
+
+		it("returns a plan that provides and requires that version of node", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: "/working-dir",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: nodeengine.Node},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{
+						Name: nodeengine.Node,
+						Metadata: nodeengine.BuildPlanMetadata{
+							Version:       ">=18 <21",
+							VersionSource: "package.json",
+						},
+					},
+				},
+				Or: []packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{
+							{Name: nodeengine.Node},
+							{Name: nodeengine.Npm},
+						},
+						Requires: []packit.BuildPlanRequirement{
+							{
+								Name: nodeengine.Node,
+								Metadata: nodeengine.BuildPlanMetadata{
+									Version:       ">=18 <21",
+									VersionSource: "package.json",
+								},
+							},
+						},
+					},
+				},
+			}))
+
+			Expect(packageJSONParser.ParseVersionCall.Receives.Path).To(Equal("/working-dir/package.json"))
+		})
+	})
+
+	context("when the package.json contains a volta configuration", func() {
+		it.Before(func() {
+			voltaParser.ParseToolchainCall.Returns.Toolchain = nodeengine.Toolchain{
+				Node: "20.11.1",
+				Npm:  "10.2.4",
+				Yarn: "1.22.19",
+			}
+		})
+
+		it("returns a plan that requires the pinned version of node with the pinned package managers", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: "/working-dir",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "20.11.1",
+						VersionSource: "volta",
+						Npm:           "10.2.4",
+						Yarn:          "1.22.19",
+					},
+				},
+			}))
+			Expect(result.Plan.Or[0].Requires).To(Equal(result.Plan.Requires))
+
+			Expect(voltaParser.ParseToolchainCall.Receives.WorkingDir).To(Equal("/working-dir"))
+			Expect(voltaParser.ParseToolchainCall.Receives.Path).To(Equal("/working-dir/package.json"))
+		})
+	})
+
+	context("when the volta configuration only pins yarn", func() {
+		it.Before(func() {
+			voltaParser.ParseToolchainCall.Returns.Toolchain = nodeengine.Toolchain{
+				Yarn: "1.22.19",
+			}
+			packageJSONParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{
+				Raw:        "20.x",
+				Constraint: "20.x",
+				Path:       "/working-dir/package.json",
+			}
+			nvmrcParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{
+				Raw:        "18",
+				Constraint: "18.*.*",
+				Path:       "/working-dir/.nvmrc",
+			}
+		})
+
+		it("attaches the yarn pin to the requirements of the other sources", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: "/working-dir",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "18.*.*",
+						VersionSource: ".nvmrc",
+						Yarn:          "1.22.19",
+					},
+				},
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "20.x",
+						VersionSource: "package.json",
+						Yarn:          "1.22.19",
+					},
+				},
+			}))
+		})
+
+		context("when no other source requests a version", func() {
+			it.Before(func() {
+				packageJSONParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{}
+				nvmrcParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{}
+			})
+
+			it("carries the yarn pin in a requirement without a version", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name: nodeengine.Node,
+						Metadata: nodeengine.BuildPlanMetadata{
+							VersionSource: "volta",
+							Yarn:          "1.22.19",
+						},
+					},
+				}))
+			})
+		})
+	})
+
+	context("when the package.json contains a devEngines.runtime field", func() {
+		it.Before(func() {
+			runtimeParser.ParseRuntimeCall.Returns.Runtime = nodeengine.Runtime{
+				Version: "^22",
+				OnFail:  "warn",
+			}
+		})
+
+		it("returns a plan that requires that version of node along with the onFail behavior", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: "/working-dir",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "^22",
+						VersionSource: "devEngines",
+						OnFail:        "warn",
+					},
+				},
+			}))
+			Expect(result.Plan.Or[0].Requires).To(Equal(result.Plan.Requires))
+
+			Expect(runtimeParser.ParseRuntimeCall.Receives.Path).To(Equal("/working-dir/package.json"))
+		})
+	})
+
+	context("when the source code contains a .tool-versions file", func() {
+		it.Before(func() {
+			toolVersionsParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: "20.11.1 || 18.19.0"}
+		})
+
+		it("returns a plan that provides and requires that version of node", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: "/working-dir",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "20.11.1 || 18.19.0",
+						VersionSource: ".tool-versions",
+					},
+				},
+			}))
+			Expect(result.Plan.Or[0].Requires).To(Equal(result.Plan.Requires))
+
+			Expect(toolVersionsParser.ParseVersionCall.Receives.Path).To(Equal("/working-dir/.tool-versions"))
+		})
+	})
+
+	context("when the source code contains mise configuration files", func() {
+		var paths []string
+
+		it.Before(func() {
+			paths = nil
+			miseParser.ParseVersionCall.Stub = func(path string) (nodeengine.VersionResult, error) {
+				paths = append(paths, path)
+				if filepath.Base(path) == ".mise.toml" {
+					return nodeengine.VersionResult{Constraint: "22"}, nil
+				}
+				return nodeengine.VersionResult{Constraint: "20"}, nil
+			}
+		})
+
+		it("returns a plan that requires the version from each file", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: "/working-dir",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "20",
+						VersionSource: "mise.toml",
+					},
+				},
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "22",
+						VersionSource: ".mise.toml",
+					},
+				},
+			}))
+			Expect(result.Plan.Or[0].Requires).To(Equal(result.Plan.Requires))
+
+			Expect(paths).To(Equal([]string{"/working-dir/mise.toml", "/working-dir/.mise.toml"}))
+		})
+	})
+
 	context("when the source code contains .nvmrc and .node-version files", func() {
 		it.Before(func() {
-			nvmrcParser.ParseVersionCall.Returns.Version = "1.2.3"
-			nodeVersionParser.ParseVersionCall.Returns.Version = "7.8.9"
+			nvmrcParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: "1.2.3"}
+			nodeVersionParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: "7.8.9"}
 		})
 
 		it("returns a plan that provides and requires that version of node", func() {
@@ -250,88 +587,744 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
-	context("when $BP_NODE_PROJECT_PATH is set", func() {
+	context("when the package.json declares a packageManager", func() {
 		var workingDir string
-		it.Before(func() {
-			var err error
-			workingDir, err = os.MkdirTemp("", "working-dir")
-			Expect(err).NotTo(HaveOccurred())
-			err = os.MkdirAll(filepath.Join(workingDir, "custom", "path"), os.ModePerm)
-			Expect(err).NotTo(HaveOccurred())
-
-			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "custom/path")).To(Succeed())
-		})
 
-		it.After(func() {
-			Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
-			err := os.RemoveAll(workingDir)
-			Expect(err).NotTo(HaveOccurred())
+		it.Before(func() {
+			workingDir = t.TempDir()
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"packageManager": "pnpm@9.12.0"}`), 0600)).To(Succeed())
 		})
 
-		it("it uses the custom path for config file parsers", func() {
-			_, err := detect(packit.DetectContext{
+		it("returns a plan that provides and requires corepack", func() {
+			result, err := detect(packit.DetectContext{
 				WorkingDir: workingDir,
 			})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: nodeengine.Node},
+					{Name: nodeengine.Corepack},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{
+						Name:     nodeengine.Corepack,
+						Metadata: nodeengine.CorepackPlanMetadata{PackageManager: "pnpm@9.12.0"},
+					},
+				},
+				Or: []packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{
+							{Name: nodeengine.Node},
+							{Name: nodeengine.Corepack},
+							{Name: nodeengine.Npm},
+						},
+						Requires: []packit.BuildPlanRequirement{
+							{
+								Name:     nodeengine.Corepack,
+								Metadata: nodeengine.CorepackPlanMetadata{PackageManager: "pnpm@9.12.0"},
+							},
+						},
+					},
+				},
+			}))
 
-			Expect(nvmrcParser.ParseVersionCall.Receives.Path).To(Equal(fmt.Sprintf("%s/.nvmrc", filepath.Join(workingDir, "custom", "path"))))
-			Expect(nodeVersionParser.ParseVersionCall.Receives.Path).To(Equal(fmt.Sprintf("%s/.node-version", filepath.Join(workingDir, "custom", "path"))))
+			Expect(buffer.String()).To(ContainSubstring(`Enabling corepack for package manager "pnpm@9.12.0" from package.json`))
 		})
-	})
-
-	context("failure cases", func() {
-		context("when the dir specified by BP_NODE_PROJECT_PATH does not exist", func() {
-			var workingDir string
 
+		context("when $BP_NODE_ENABLE_COREPACK is false", func() {
 			it.Before(func() {
-				var err error
-				workingDir, err = os.MkdirTemp("", "working-dir")
-				Expect(err).NotTo(HaveOccurred())
-				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "src/does/not/exist")).To(Succeed())
-			})
-
-			it.After(func() {
-				Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+				t.Setenv("BP_NODE_ENABLE_COREPACK", "false")
 			})
 
-			it("fails with helpful error", func() {
-				_, err := detect(packit.DetectContext{
+			it("does not provide corepack", func() {
+				result, err := detect(packit.DetectContext{
 					WorkingDir: workingDir,
 				})
-				Expect(err).To(
-					MatchError(
-						ContainSubstring(
-							fmt.Sprintf("expected value derived from BP_NODE_PROJECT_PATH [%s] to be an existing directory", filepath.Join(workingDir, "src", "does", "not", "exist")),
-						),
-					),
-				)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Provides).To(Equal([]packit.BuildPlanProvision{
+					{Name: nodeengine.Node},
+				}))
+				Expect(result.Plan.Requires).To(BeEmpty())
 			})
 		})
+	})
 
-		context("when the nvmrc parser fails", func() {
-			it.Before(func() {
-				nvmrcParser.ParseVersionCall.Returns.Err = errors.New("failed to parse .nvmrc")
-			})
+	context("when the package.json declares npm as its packageManager", func() {
+		var workingDir string
 
-			it("returns an error", func() {
-				_, err := detect(packit.DetectContext{
-					WorkingDir: "/working-dir",
-				})
-				Expect(err).To(MatchError("failed to parse .nvmrc"))
-			})
+		it.Before(func() {
+			workingDir = t.TempDir()
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"packageManager": "npm@10.9.2"}`), 0600)).To(Succeed())
 		})
 
-		context("when the .node-version parser fails", func() {
-			it.Before(func() {
-				nodeVersionParser.ParseVersionCall.Returns.Err = errors.New("failed to parse .node-version")
+		it("does not provide corepack", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
 			})
-
-			it("returns an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Provides).To(Equal([]packit.BuildPlanProvision{
+				{Name: nodeengine.Node},
+			}))
+			Expect(result.Plan.Requires).To(BeEmpty())
+		})
+	})
+
+	context("when $BP_NODE_ENABLE_COREPACK is true", func() {
+		it.Before(func() {
+			t.Setenv("BP_NODE_ENABLE_COREPACK", "true")
+		})
+
+		it("provides and requires corepack without a package manager", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: t.TempDir(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Provides).To(ContainElement(packit.BuildPlanProvision{Name: nodeengine.Corepack}))
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name:     nodeengine.Corepack,
+					Metadata: nodeengine.CorepackPlanMetadata{},
+				},
+			}))
+		})
+	})
+
+	context("when the package.json pins an npm version in engines.npm", func() {
+		var workingDir string
+
+		it.Before(func() {
+			workingDir = t.TempDir()
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"engines": {"npm": "10.9.x"}}`), 0600)).To(Succeed())
+		})
+
+		it("returns a plan that provides and requires that version of npm", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: nodeengine.Node},
+					{Name: nodeengine.Npm},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{
+						Name: nodeengine.Npm,
+						Metadata: nodeengine.BuildPlanMetadata{
+							Version:       "10.9.x",
+							VersionSource: "package.json",
+						},
+					},
+				},
+			}))
+		})
+
+		context("when the engines.npm field is not a valid constraint", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"engines": {"npm": "latest"}}`), 0600)).To(Succeed())
+			})
+
+			it("does not require npm", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(BeEmpty())
+				Expect(result.Plan.Or).To(HaveLen(1))
+			})
+		})
+
+		context("when $BP_NPM_VERSION is set", func() {
+			it.Before(func() {
+				t.Setenv("BP_NPM_VERSION", "11.*")
+			})
+
+			it("requires the version of $BP_NPM_VERSION instead", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name: nodeengine.Npm,
+						Metadata: nodeengine.BuildPlanMetadata{
+							Version:       "11.*",
+							VersionSource: "BP_NPM_VERSION",
+						},
+					},
+				}))
+			})
+		})
+	})
+
+	context("when $BP_NODE_VERSION_SOURCES is set", func() {
+		it.Before(func() {
+			nvmrcParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: "1.2.3"}
+			nodeVersionParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: "7.8.9"}
+			packageJSONParser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Constraint: ">=18 <21"}
+		})
+
+		context("when it lists the sources to enable", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_SOURCES", "package.json, .node-version")
+			})
+
+			it("only consults those sources, in registry order", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name: nodeengine.Node,
+						Metadata: nodeengine.BuildPlanMetadata{
+							Version:       "7.8.9",
+							VersionSource: ".node-version",
+						},
+					},
+					{
+						Name: nodeengine.Node,
+						Metadata: nodeengine.BuildPlanMetadata{
+							Version:       ">=18 <21",
+							VersionSource: "package.json",
+						},
+					},
+				}))
+
+				Expect(nvmrcParser.ParseVersionCall.CallCount).To(Equal(0))
+				Expect(voltaParser.ParseToolchainCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when it lists the sources to disable", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_SOURCES", "-.nvmrc,-package.json")
+			})
+
+			it("consults every other source", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name: nodeengine.Node,
+						Metadata: nodeengine.BuildPlanMetadata{
+							Version:       "7.8.9",
+							VersionSource: ".node-version",
+						},
+					},
+				}))
+
+				Expect(nvmrcParser.ParseVersionCall.CallCount).To(Equal(0))
+				Expect(packageJSONParser.ParseVersionCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("when $BP_NODE_WORKSPACES is set", func() {
+		var (
+			workingDir string
+			cnbDir     string
+		)
+
+		it.Before(func() {
+			workingDir = t.TempDir()
+			for _, dir := range []string{"apps/web", "apps/api", "apps/legacy", "tools/cli", "docs"} {
+				Expect(os.MkdirAll(filepath.Join(workingDir, dir), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, dir, "package.json"), []byte(`{}`), 0600)).To(Succeed())
+			}
+
+			cnbDir = t.TempDir()
+			Expect(os.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(`
+[[metadata.dependencies]]
+  id = "node"
+  version = "20.11.1"
+  stacks = ["*"]
+
+[[metadata.dependencies]]
+  id = "node"
+  version = "22.1.0"
+  stacks = ["*"]
+`), 0600)).To(Succeed())
+
+			nvmrcParser.ParseVersionCall.Stub = func(path string) (nodeengine.VersionResult, error) {
+				switch filepath.Dir(path) {
+				case filepath.Join(workingDir, "apps", "web"):
+					return nodeengine.VersionResult{Constraint: "20.*", Path: path}, nil
+				case filepath.Join(workingDir, "apps", "api"):
+					return nodeengine.VersionResult{Constraint: ">=20", Path: path}, nil
+				}
+				return nodeengine.VersionResult{}, nil
+			}
+
+			t.Setenv("BP_NODE_VERSION", "20.11.1")
+		})
+
+		it("requires the version of every listed workspace", func() {
+			t.Setenv("BP_NODE_WORKSPACES", "apps/web, apps/api")
+
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "20.11.1",
+						VersionSource: "BP_NODE_VERSION",
+						Workspace:     ".",
+					},
+				},
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       "20.*",
+						VersionSource: ".nvmrc",
+						Workspace:     "apps/web",
+					},
+				},
+				{
+					Name: nodeengine.Node,
+					Metadata: nodeengine.BuildPlanMetadata{
+						Version:       ">=20",
+						VersionSource: ".nvmrc",
+						Workspace:     "apps/api",
+					},
+				},
+			}))
+			Expect(result.Plan.Or[0].Requires).To(Equal(result.Plan.Requires))
+		})
+
+		context("when the workspaces are discovered", func() {
+			var paths []string
+
+			it.Before(func() {
+				t.Setenv("BP_NODE_WORKSPACES", "auto")
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"workspaces": {"packages": ["apps/*", "!apps/legacy"]}}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), []byte("packages:\n  - tools/*\n"), 0600)).To(Succeed())
+
+				paths = nil
+				packageJSONParser.ParseVersionCall.Stub = func(path string) (nodeengine.VersionResult, error) {
+					paths = append(paths, path)
+					return nodeengine.VersionResult{}, nil
+				}
+			})
+
+			it("consults the packages matched by the workspace patterns", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(paths).To(Equal([]string{
+					filepath.Join(workingDir, "package.json"),
+					filepath.Join(workingDir, "apps", "api", "package.json"),
+					filepath.Join(workingDir, "apps", "web", "package.json"),
+					filepath.Join(workingDir, "tools", "cli", "package.json"),
+				}))
+			})
+
+			context("when a workspace pattern has a ** segment", func() {
+				it.Before(func() {
+					for _, dir := range []string{"libs/ui", "libs/data/core", "libs/data/internal", "libs/ui/node_modules/dep"} {
+						Expect(os.MkdirAll(filepath.Join(workingDir, dir), os.ModePerm)).To(Succeed())
+						Expect(os.WriteFile(filepath.Join(workingDir, dir, "package.json"), []byte(`{}`), 0600)).To(Succeed())
+					}
+
+					Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"workspaces": ["libs/**", "!libs/**/internal"]}`), 0600)).To(Succeed())
+				})
+
+				it("consults the packages nested at any depth, outside of node_modules", func() {
+					_, err := detect(packit.DetectContext{
+						WorkingDir: workingDir,
+						CNBPath:    cnbDir,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(paths).To(Equal([]string{
+						filepath.Join(workingDir, "package.json"),
+						filepath.Join(workingDir, "libs", "data", "core", "package.json"),
+						filepath.Join(workingDir, "libs", "ui", "package.json"),
+						filepath.Join(workingDir, "tools", "cli", "package.json"),
+					}))
+				})
+			})
+
+			context("when the pnpm-workspace.yaml is a symlink to a file outside of the working directory", func() {
+				it.Before(func() {
+					outside := filepath.Join(t.TempDir(), "pnpm-workspace.yaml")
+					Expect(os.WriteFile(outside, []byte("packages:\n  - tools/*\n"), 0600)).To(Succeed())
+
+					Expect(os.Remove(filepath.Join(workingDir, "pnpm-workspace.yaml"))).To(Succeed())
+					Expect(os.Symlink(outside, filepath.Join(workingDir, "pnpm-workspace.yaml"))).To(Succeed())
+				})
+
+				it("fails with helpful error", func() {
+					_, err := detect(packit.DetectContext{
+						WorkingDir: workingDir,
+						CNBPath:    cnbDir,
+					})
+					Expect(err).To(MatchError(fmt.Sprintf("expected workspaces version file [%s] to be inside the application directory [%s]", filepath.Join(workingDir, "pnpm-workspace.yaml"), workingDir)))
+				})
+			})
+		})
+
+		context("when the workspaces have incompatible requirements", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_WORKSPACES", "apps/web,apps/api")
+				t.Setenv("BP_NODE_VERSION", "")
+				nvmrcParser.ParseVersionCall.Stub = func(path string) (nodeengine.VersionResult, error) {
+					switch filepath.Dir(path) {
+					case filepath.Join(workingDir, "apps", "web"):
+						return nodeengine.VersionResult{Constraint: "20.*", Path: path}, nil
+					case filepath.Join(workingDir, "apps", "api"):
+						return nodeengine.VersionResult{Constraint: "^22", Path: path}, nil
+					}
+					return nodeengine.VersionResult{}, nil
+				}
+			})
+
+			it("returns an error listing the requirement of every workspace", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(`workspaces have incompatible Node version requirements: no version of node satisfies every version requirement:
+  .nvmrc (apps/web) -> "20.*" (matches 20.11.1)
+  .nvmrc (apps/api) -> "^22" (matches 22.1.0)`))
+			})
+		})
+
+		context("when a listed workspace does not exist", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_WORKSPACES", "apps/web,apps/missing")
+			})
+
+			it("fails with a helpful message", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("expected value derived from BP_NODE_WORKSPACES [%s] to be an existing directory", filepath.Join(workingDir, "apps", "missing"))))
+			})
+		})
+	})
+
+	context("when $BP_NODE_PROJECT_PATH is set", func() {
+		var workingDir string
+		it.Before(func() {
+			var err error
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+			err = os.MkdirAll(filepath.Join(workingDir, "custom", "path"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "custom/path")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+			err := os.RemoveAll(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("it uses the custom path for config file parsers", func() {
+			_, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(nvmrcParser.ParseVersionCall.Receives.Path).To(Equal(fmt.Sprintf("%s/.nvmrc", filepath.Join(workingDir, "custom", "path"))))
+			Expect(nodeVersionParser.ParseVersionCall.Receives.Path).To(Equal(fmt.Sprintf("%s/.node-version", filepath.Join(workingDir, "custom", "path"))))
+			Expect(packageJSONParser.ParseVersionCall.Receives.Path).To(Equal(fmt.Sprintf("%s/package.json", filepath.Join(workingDir, "custom", "path"))))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the dir specified by BP_NODE_PROJECT_PATH does not exist", func() {
+			var workingDir string
+
+			it.Before(func() {
+				var err error
+				workingDir, err = os.MkdirTemp("", "working-dir")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "src/does/not/exist")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+			})
+
+			it("fails with helpful error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(
+					MatchError(
+						ContainSubstring(
+							fmt.Sprintf("expected value derived from BP_NODE_PROJECT_PATH [%s] to be an existing directory", filepath.Join(workingDir, "src", "does", "not", "exist")),
+						),
+					),
+				)
+			})
+		})
+
+		context("when the package.json is a symlink to a file outside of the working directory", func() {
+			var workingDir string
+
+			it.Before(func() {
+				workingDir = t.TempDir()
+				outside := filepath.Join(t.TempDir(), "package.json")
+				Expect(os.WriteFile(outside, []byte(`{"engines": {"npm": "10.9.x"}}`), 0600)).To(Succeed())
+				Expect(os.Symlink(outside, filepath.Join(workingDir, "package.json"))).To(Succeed())
+
+				t.Setenv("BP_NODE_VERSION_SOURCES", ".nvmrc")
+				t.Setenv("BP_NODE_ENABLE_COREPACK", "false")
+			})
+
+			it("fails with helpful error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("expected npm version file [%s] to be inside the application directory [%s]", filepath.Join(workingDir, "package.json"), workingDir)))
+			})
+		})
+
+		context("when the package.json declaring a packageManager is a symlink to a file outside of the working directory", func() {
+			var workingDir string
+
+			it.Before(func() {
+				workingDir = t.TempDir()
+				outside := filepath.Join(t.TempDir(), "package.json")
+				Expect(os.WriteFile(outside, []byte(`{"packageManager": "pnpm@9.12.0"}`), 0600)).To(Succeed())
+				Expect(os.Symlink(outside, filepath.Join(workingDir, "package.json"))).To(Succeed())
+
+				t.Setenv("BP_NODE_VERSION_SOURCES", ".nvmrc")
+			})
+
+			it("fails with helpful error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("expected corepack version file [%s] to be inside the application directory [%s]", filepath.Join(workingDir, "package.json"), workingDir)))
+			})
+		})
+
+		context("when $BP_NODE_ENABLE_COREPACK is not a boolean", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_ENABLE_COREPACK", "sometimes")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: t.TempDir(),
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_ENABLE_COREPACK value sometimes")))
+			})
+		})
+
+		context("when BP_NODE_PROJECT_PATH escapes the working directory", func() {
+			var workingDir string
+
+			it.Before(func() {
+				workingDir = filepath.Join(t.TempDir(), "app")
+				Expect(os.MkdirAll(workingDir, os.ModePerm)).To(Succeed())
+				t.Setenv("BP_NODE_PROJECT_PATH", "../")
+			})
+
+			it("fails with helpful error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("expected value derived from BP_NODE_PROJECT_PATH [%s] to be inside the application directory [%s]", filepath.Dir(workingDir), workingDir)))
+				Expect(nvmrcParser.ParseVersionCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when BP_NODE_PROJECT_PATH is a symlink to a directory outside of the working directory", func() {
+			var workingDir string
+
+			it.Before(func() {
+				workingDir = t.TempDir()
+				Expect(os.Symlink(t.TempDir(), filepath.Join(workingDir, "src"))).To(Succeed())
+				t.Setenv("BP_NODE_PROJECT_PATH", "src")
+			})
+
+			it("fails with helpful error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("expected value derived from BP_NODE_PROJECT_PATH [%s] to be inside the application directory [%s]", filepath.Join(workingDir, "src"), workingDir)))
+			})
+		})
+
+		context("when a workspace is a symlink to a directory outside of the working directory", func() {
+			var workingDir string
+
+			it.Before(func() {
+				workingDir = t.TempDir()
+				Expect(os.Symlink(t.TempDir(), filepath.Join(workingDir, "shared"))).To(Succeed())
+				t.Setenv("BP_NODE_WORKSPACES", "shared")
+			})
+
+			it("fails with helpful error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("expected workspace [%s] to be inside the application directory [%s]", filepath.Join(workingDir, "shared"), workingDir)))
+			})
+		})
+
+		context("when $BP_NODE_VERSION_SOURCES names an unknown source", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION_SOURCES", ".nvmrc,.python-version")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError(ContainSubstring(`unknown version source ".python-version" in BP_NODE_VERSION_SOURCES`)))
+			})
+		})
+
+		context("when $BP_NODE_VERSION is not a valid version constraint", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_VERSION", "not-a-version")
+				versionResolver.ResolveVersionCall.Stub = nil
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError(`invalid version constraint specified in BP_NODE_VERSION: "not-a-version"`))
+			})
+		})
+
+		context("when $BP_NODE_VERSION fails to resolve", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_VERSION", "lts")).To(Succeed())
+				versionResolver.ResolveVersionCall.Stub = nil
+				versionResolver.ResolveVersionCall.Returns.Err = errors.New("failed to read LTS codenames")
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_VERSION")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to read LTS codenames"))
+			})
+		})
+
+		context("when the buildpack.yml parser fails", func() {
+			it.Before(func() {
+				buildpackYMLParser.ParseVersionCall.Returns.Err = errors.New("failed to parse buildpack.yml")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to parse buildpack.yml"))
+			})
+		})
+
+		context("when the nvmrc parser fails", func() {
+			it.Before(func() {
+				nvmrcParser.ParseVersionCall.Returns.Err = errors.New("failed to parse .nvmrc")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to parse .nvmrc"))
+			})
+		})
+
+		context("when the .node-version parser fails", func() {
+			it.Before(func() {
+				nodeVersionParser.ParseVersionCall.Returns.Err = errors.New("failed to parse .node-version")
+			})
+
+			it("returns an error", func() {
 				_, err := detect(packit.DetectContext{
 					WorkingDir: "/working-dir",
 				})
 				Expect(err).To(MatchError("failed to parse .node-version"))
 			})
 		})
+
+		context("when the volta parser fails", func() {
+			it.Before(func() {
+				voltaParser.ParseToolchainCall.Returns.Err = errors.New("failed to parse volta configuration")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to parse volta configuration"))
+			})
+		})
+
+		context("when the devEngines parser fails", func() {
+			it.Before(func() {
+				runtimeParser.ParseRuntimeCall.Returns.Err = errors.New("failed to parse devEngines")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to parse devEngines"))
+			})
+		})
+
+		context("when the .tool-versions parser fails", func() {
+			it.Before(func() {
+				toolVersionsParser.ParseVersionCall.Returns.Err = errors.New("failed to parse .tool-versions")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to parse .tool-versions"))
+			})
+		})
+
+		context("when the mise parser fails", func() {
+			it.Before(func() {
+				miseParser.ParseVersionCall.Returns.Err = errors.New("failed to parse mise.toml")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to parse mise.toml"))
+			})
+		})
+
+		context("when the package.json parser fails", func() {
+			it.Before(func() {
+				packageJSONParser.ParseVersionCall.Returns.Err = errors.New("failed to parse package.json")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to parse package.json"))
+			})
+		})
 	})
 }
@@ -0,0 +1,88 @@
+package nodeengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	OnFailIgnore   = "ignore"
+	OnFailWarn     = "warn"
+	OnFailError    = "error"
+	OnFailDownload = "download"
+)
+
+// Runtime describes a runtime requirement along with the behavior expected
+// when the installed runtime does not satisfy it.
+type Runtime struct {
+	Version string
+	OnFail  string
+}
+
+type DevEnginesParser struct{}
+
+func NewDevEnginesParser() DevEnginesParser {
+	return DevEnginesParser{}
+}
+
+// ParseRuntime reads the node entry of the devEngines.runtime field of the
+// package.json at the given path. The field can either be a single runtime
+// or a list of runtimes. As with npm, onFail defaults to "error".
+func (p DevEnginesParser) ParseRuntime(path string) (Runtime, error) {
+	pkg, err := parsePackageJSON(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Runtime{}, nil
+		}
+		return Runtime{}, err
+	}
+
+	if len(pkg.DevEngines.Runtime) == 0 {
+		return Runtime{}, nil
+	}
+
+	type devEngine struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		OnFail  string `json:"onFail"`
+	}
+
+	var runtimes []devEngine
+	if strings.HasPrefix(strings.TrimSpace(string(pkg.DevEngines.Runtime)), "[") {
+		err = json.Unmarshal(pkg.DevEngines.Runtime, &runtimes)
+	} else {
+		runtimes = make([]devEngine, 1)
+		err = json.Unmarshal(pkg.DevEngines.Runtime, &runtimes[0])
+	}
+	if err != nil {
+		return Runtime{}, fmt.Errorf("failed to parse devEngines.runtime in package.json: %w", err)
+	}
+
+	for _, runtime := range runtimes {
+		if runtime.Name != Node || strings.TrimSpace(runtime.Version) == "" {
+			continue
+		}
+
+		version := strings.TrimSpace(strings.ToLower(runtime.Version))
+		if _, err := semver.NewConstraint(version); err != nil {
+			return Runtime{}, fmt.Errorf("invalid version constraint specified in package.json devEngines.runtime: %q", version)
+		}
+
+		onFail := runtime.OnFail
+		switch onFail {
+		case "":
+			onFail = OnFailError
+		case OnFailIgnore, OnFailWarn, OnFailError, OnFailDownload:
+		default:
+			return Runtime{}, fmt.Errorf("invalid onFail value specified in package.json devEngines.runtime: %q, expected one of ignore, warn, error or download", onFail)
+		}
+
+		return Runtime{Version: version, OnFail: onFail}, nil
+	}
+
+	return Runtime{}, nil
+}
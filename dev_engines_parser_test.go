@@ -0,0 +1,160 @@
+package nodeengine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDevEnginesParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser nodeengine.DevEnginesParser
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "package.json")
+
+		parser = nodeengine.NewDevEnginesParser()
+	})
+
+	it("returns the node runtime requirement", func() {
+		Expect(os.WriteFile(path, []byte(`{
+			"devEngines": {"runtime": {"name": "node", "version": "^22", "onFail": "warn"}}
+		}`), 0644)).To(Succeed())
+
+		runtime, err := parser.ParseRuntime(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtime).To(Equal(nodeengine.Runtime{
+			Version: "^22",
+			OnFail:  "warn",
+		}))
+	})
+
+	it("returns a release candidate requirement", func() {
+		Expect(os.WriteFile(path, []byte(`{
+			"devEngines": {"runtime": {"name": "node", "version": "23.0.0-rc.1"}}
+		}`), 0644)).To(Succeed())
+
+		runtime, err := parser.ParseRuntime(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtime.Version).To(Equal("23.0.0-rc.1"))
+	})
+
+	context("when the runtime is a list", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte(`{
+				"devEngines": {"runtime": [
+					{"name": "bun", "version": "^1"},
+					{"name": "node", "version": ">=20 <23", "onFail": "ignore"}
+				]}
+			}`), 0644)).To(Succeed())
+		})
+
+		it("returns the node runtime requirement", func() {
+			runtime, err := parser.ParseRuntime(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runtime).To(Equal(nodeengine.Runtime{
+				Version: ">=20 <23",
+				OnFail:  "ignore",
+			}))
+		})
+	})
+
+	context("when onFail is not specified", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte(`{
+				"devEngines": {"runtime": {"name": "node", "version": "22"}}
+			}`), 0644)).To(Succeed())
+		})
+
+		it("defaults to error", func() {
+			runtime, err := parser.ParseRuntime(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runtime).To(Equal(nodeengine.Runtime{
+				Version: "22",
+				OnFail:  "error",
+			}))
+		})
+	})
+
+	context("when the runtime is not node", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte(`{
+				"devEngines": {"runtime": {"name": "deno", "version": "^2"}}
+			}`), 0644)).To(Succeed())
+		})
+
+		it("returns an empty runtime", func() {
+			runtime, err := parser.ParseRuntime(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runtime).To(Equal(nodeengine.Runtime{}))
+		})
+	})
+
+	context("when the package.json has no devEngines field", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte(`{"engines": {"node": "22"}}`), 0644)).To(Succeed())
+		})
+
+		it("returns an empty runtime", func() {
+			runtime, err := parser.ParseRuntime(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runtime).To(Equal(nodeengine.Runtime{}))
+		})
+	})
+
+	context("when the package.json file does not exist", func() {
+		it("returns an empty runtime", func() {
+			runtime, err := parser.ParseRuntime(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runtime).To(Equal(nodeengine.Runtime{}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the runtime is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`{"devEngines": {"runtime": "node"}}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseRuntime(path)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse devEngines.runtime in package.json")))
+			})
+		})
+
+		context("when the version is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`{
+					"devEngines": {"runtime": {"name": "node", "version": "not-a-version"}}
+				}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseRuntime(path)
+				Expect(err).To(MatchError("invalid version constraint specified in package.json devEngines.runtime: \"not-a-version\""))
+			})
+		})
+
+		context("when onFail is not a known value", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`{
+					"devEngines": {"runtime": {"name": "node", "version": "22", "onFail": "explode"}}
+				}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseRuntime(path)
+				Expect(err).To(MatchError(ContainSubstring(`invalid onFail value specified in package.json devEngines.runtime: "explode"`)))
+			})
+		})
+	})
+}
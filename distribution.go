@@ -0,0 +1,240 @@
+package nodeengine
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+// DistributionSource is the version source reported for a Node distribution
+// installed from BP_NODE_DISTRIBUTION_URL.
+const DistributionSource = "BP_NODE_DISTRIBUTION_URL"
+
+var (
+	distributionVersionPattern = regexp.MustCompile(`^node-v?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.]+?)?)-(?:linux|darwin|win|aix|sunos)-`)
+	sha256Pattern              = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// distributionFromEnvironment returns a dependency for the Node distribution
+// at BP_NODE_DISTRIBUTION_URL, or an empty dependency when it is not set. The
+// download is verified against the checksum given by
+// BP_NODE_DISTRIBUTION_SHA256, or listed for its file name in the
+// SHASUMS256.txt file at BP_NODE_DISTRIBUTION_SHASUMS_URL, whose signature is
+// verified against the release keys at the given path. The version is read
+// from the file name, like node-v22.11.0-linux-x64.tar.xz, unless it is given
+// by BP_NODE_DISTRIBUTION_VERSION.
+func distributionFromEnvironment(keysPath string) (postal.Dependency, error) {
+	uri := os.Getenv("BP_NODE_DISTRIBUTION_URL")
+	if uri == "" {
+		return postal.Dependency{}, nil
+	}
+
+	if !isHTTPURL(uri) {
+		return postal.Dependency{}, fmt.Errorf("invalid value for BP_NODE_DISTRIBUTION_URL: %q, expected an http or https URL", uri)
+	}
+
+	parsed, _ := url.Parse(uri)
+	filename := path.Base(parsed.Path)
+
+	version := os.Getenv("BP_NODE_DISTRIBUTION_VERSION")
+	if version == "" {
+		match := distributionVersionPattern.FindStringSubmatch(filename)
+		if match == nil {
+			return postal.Dependency{}, fmt.Errorf("failed to read the Node version from the file name of BP_NODE_DISTRIBUTION_URL %q: set BP_NODE_DISTRIBUTION_VERSION", filename)
+		}
+		version = match[1]
+	}
+
+	version = strings.TrimPrefix(version, "v")
+	if _, err := semver.StrictNewVersion(version); err != nil {
+		return postal.Dependency{}, fmt.Errorf("invalid Node version of BP_NODE_DISTRIBUTION_URL: %q: %w", version, err)
+	}
+
+	checksum, err := distributionChecksum(filename, keysPath)
+	if err != nil {
+		return postal.Dependency{}, err
+	}
+
+	return nodeDistribution(uri, version, checksum), nil
+}
+
+// distributionChecksum returns the SHA-256 checksum that the distribution
+// with the given file name is verified against.
+func distributionChecksum(filename, keysPath string) (string, error) {
+	checksum := strings.ToLower(strings.TrimSpace(os.Getenv("BP_NODE_DISTRIBUTION_SHA256")))
+	shasumsURI := os.Getenv("BP_NODE_DISTRIBUTION_SHASUMS_URL")
+
+	switch {
+	case checksum != "" && shasumsURI != "":
+		return "", errors.New("BP_NODE_DISTRIBUTION_SHA256 and BP_NODE_DISTRIBUTION_SHASUMS_URL cannot both be set")
+	case checksum != "":
+		if !sha256Pattern.MatchString(checksum) {
+			return "", fmt.Errorf("invalid value for BP_NODE_DISTRIBUTION_SHA256: %q, expected a hex-encoded SHA-256 checksum", checksum)
+		}
+		return checksum, nil
+	case shasumsURI != "":
+		return shasumsChecksum(shasumsURI, filename, keysPath)
+	default:
+		return "", errors.New("BP_NODE_DISTRIBUTION_URL requires BP_NODE_DISTRIBUTION_SHA256 or BP_NODE_DISTRIBUTION_SHASUMS_URL to verify the download")
+	}
+}
+
+// shasumsChecksum returns the checksum listed for the given file name in the
+// SHASUMS256.txt file at the given URI, once its signature is verified against
+// the release keys at the given path.
+func shasumsChecksum(uri, filename, keysPath string) (string, error) {
+	keys, err := NewReleaseKeys(keysPath)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := keys.VerifiedSHASUMS(uri)
+	if err != nil {
+		return "", err
+	}
+
+	return checksumFor(content, uri, filename)
+}
+
+// checksumFor returns the checksum listed for the given file name in the
+// content of the SHASUMS256.txt file at the given URI.
+func checksumFor(content []byte, uri, filename string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != filename {
+			continue
+		}
+
+		checksum := strings.ToLower(fields[0])
+		if !sha256Pattern.MatchString(checksum) {
+			return "", fmt.Errorf("invalid checksum for %s in %s: %q", filename, uri, fields[0])
+		}
+
+		return checksum, nil
+	}
+
+	return "", fmt.Errorf("no checksum for %s found in %s", filename, uri)
+}
+
+// fetchStatusError is returned by fetch when the server responds with an
+// error status.
+type fetchStatusError struct {
+	URI        string
+	StatusCode int
+}
+
+func (e fetchStatusError) Error() string {
+	return fmt.Sprintf("failed to fetch %s: unexpected status code %d", e.URI, e.StatusCode)
+}
+
+// fetchClient is used to fetch the files that versions are resolved and
+// verified with, so that an unresponsive host fails the build instead of
+// hanging it.
+var fetchClient = &http.Client{Timeout: 30 * time.Second}
+
+// fetch returns the content at the given URI.
+func fetch(uri string) ([]byte, error) {
+	response, err := fetchClient.Get(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", uri, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 400 {
+		return nil, fetchStatusError{URI: uri, StatusCode: response.StatusCode}
+	}
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", uri, err)
+	}
+
+	return content, nil
+}
+
+// nodeDistribution returns a dependency for the Node archive at the given URI.
+func nodeDistribution(uri, version, checksum string) postal.Dependency {
+	return postal.Dependency{
+		ID:       Node,
+		Name:     "Node Engine",
+		Version:  version,
+		URI:      uri,
+		Source:   uri,
+		Checksum: fmt.Sprintf("sha256:%s", checksum),
+		CPE:      fmt.Sprintf("cpe:2.3:a:nodejs:node.js:%s:*:*:*:*:*:*:*", version),
+		PURL:     fmt.Sprintf("pkg:generic/node@v%s?checksum=%s&download_url=%s", version, checksum, uri),
+		Stacks:   []string{"*"},
+	}
+}
+
+// isHTTPURL reports whether the given URI is an absolute http or https URL.
+func isHTTPURL(uri string) bool {
+	parsed, err := url.Parse(uri)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// stripDistributionRoot moves the contents of the single top-level directory
+// of an extracted distribution, like node-v22.11.0-linux-x64, into the layer,
+// which is what strip-components does for the distributions listed in the
+// buildpack.toml. A distribution packaged without a top-level directory is
+// left as it is.
+func stripDistributionRoot(layerPath string) error {
+	errMissingNode := errors.New("expected the distribution to contain bin/node at its root or inside a single top-level directory")
+
+	if _, err := os.Stat(filepath.Join(layerPath, "bin", "node")); err == nil {
+		return nil
+	}
+
+	entries, err := os.ReadDir(layerPath)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return errMissingNode
+	}
+
+	root := filepath.Join(layerPath, entries[0].Name())
+	if _, err := os.Stat(filepath.Join(root, "bin", "node")); err != nil {
+		return errMissingNode
+	}
+
+	// The top-level directory is moved out of the way first, since it could
+	// contain an entry with its own name.
+	tmpRoot, err := os.MkdirTemp(layerPath, ".distribution-root-")
+	if err != nil {
+		return err
+	}
+
+	strippedRoot := filepath.Join(tmpRoot, "root")
+	if err := os.Rename(root, strippedRoot); err != nil {
+		return err
+	}
+
+	children, err := os.ReadDir(strippedRoot)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		if err := os.Rename(filepath.Join(strippedRoot, child.Name()), filepath.Join(layerPath, child.Name())); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(tmpRoot)
+}
@@ -0,0 +1,99 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	EOLPolicyIgnore = "ignore"
+	EOLPolicyWarn   = "warn"
+	EOLPolicyFail   = "fail"
+)
+
+// EOLPolicy decides what happens when the selected dependency is past its
+// deprecation date. The policy is only enforced once the grace period after
+// the deprecation date has passed; until then, a warning is printed.
+type EOLPolicy struct {
+	Policy      string
+	GracePeriod time.Duration
+}
+
+// eolPolicyFromEnvironment reads the policy from BP_NODE_EOL_POLICY, which
+// defaults to warning, and the grace period in days from
+// BP_NODE_EOL_GRACE_DAYS.
+func eolPolicyFromEnvironment() (EOLPolicy, error) {
+	policy := EOLPolicy{Policy: os.Getenv("BP_NODE_EOL_POLICY")}
+
+	switch policy.Policy {
+	case "":
+		policy.Policy = EOLPolicyWarn
+	case EOLPolicyIgnore, EOLPolicyWarn, EOLPolicyFail:
+	default:
+		return EOLPolicy{}, fmt.Errorf("invalid value for BP_NODE_EOL_POLICY: %q, expected %q, %q or %q", policy.Policy, EOLPolicyIgnore, EOLPolicyWarn, EOLPolicyFail)
+	}
+
+	if daysStr, ok := os.LookupEnv("BP_NODE_EOL_GRACE_DAYS"); ok {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil {
+			return EOLPolicy{}, fmt.Errorf("failed to parse BP_NODE_EOL_GRACE_DAYS value %s: %w", daysStr, err)
+		}
+
+		if days < 0 {
+			return EOLPolicy{}, fmt.Errorf("failed to parse BP_NODE_EOL_GRACE_DAYS value %s: must not be negative", daysStr)
+		}
+
+		policy.GracePeriod = time.Duration(days) * 24 * time.Hour
+	}
+
+	return policy, nil
+}
+
+// Reported returns the dependency as it is reported when it is selected. The
+// ignore policy drops its deprecation date, so that no deprecation notice is
+// printed for it.
+func (p EOLPolicy) Reported(dependency postal.Dependency) postal.Dependency {
+	if p.Policy == EOLPolicyIgnore {
+		dependency.DeprecationDate = time.Time{}
+	}
+
+	return dependency
+}
+
+// Check applies the policy to the given dependency at the given time. The
+// deprecation itself is already reported when the dependency is selected, so
+// only the grace period is reported here: the fail policy reports when builds
+// will start failing, and the warn policy, when a grace period is set, reports
+// when it ends or that it has ended.
+func (p EOLPolicy) Check(dependency postal.Dependency, now time.Time, logger scribe.Emitter) error {
+	if p.Policy == EOLPolicyIgnore || dependency.DeprecationDate.IsZero() || now.Before(dependency.DeprecationDate) {
+		return nil
+	}
+
+	deprecationDate := dependency.DeprecationDate.Format("2006-01-02")
+	enforcementDate := dependency.DeprecationDate.Add(p.GracePeriod)
+	graceEnded := !now.Before(enforcementDate)
+
+	switch {
+	case p.Policy == EOLPolicyFail && graceEnded:
+		return fmt.Errorf("%s %s reached its end of life on %s, and BP_NODE_EOL_POLICY is set to %q: select a supported version", dependency.Name, dependency.Version, deprecationDate, EOLPolicyFail)
+	case p.Policy == EOLPolicyFail:
+		logger.Subprocess("Builds using %s %s will fail after %s, when the BP_NODE_EOL_GRACE_DAYS grace period ends.", dependency.Name, dependency.Version, enforcementDate.Format("2006-01-02"))
+		logger.Break()
+	case p.GracePeriod == 0:
+		// The warn policy has nothing to add to the deprecation notice.
+	case graceEnded:
+		logger.Process("WARNING: The BP_NODE_EOL_GRACE_DAYS grace period for %s %s ended on %s.", dependency.Name, dependency.Version, enforcementDate.Format("2006-01-02"))
+		logger.Break()
+	default:
+		logger.Subprocess("The BP_NODE_EOL_GRACE_DAYS grace period for %s %s ends after %s.", dependency.Name, dependency.Version, enforcementDate.Format("2006-01-02"))
+		logger.Break()
+	}
+
+	return nil
+}
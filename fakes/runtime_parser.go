@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+)
+
+type RuntimeParser struct {
+	ParseRuntimeCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Path string
+		}
+		Returns struct {
+			Runtime nodeengine.Runtime
+			Err     error
+		}
+		Stub func(string) (nodeengine.Runtime, error)
+	}
+}
+
+func (f *RuntimeParser) ParseRuntime(param1 string) (nodeengine.Runtime, error) {
+	f.ParseRuntimeCall.mutex.Lock()
+	defer f.ParseRuntimeCall.mutex.Unlock()
+	f.ParseRuntimeCall.CallCount++
+	f.ParseRuntimeCall.Receives.Path = param1
+	if f.ParseRuntimeCall.Stub != nil {
+		return f.ParseRuntimeCall.Stub(param1)
+	}
+	return f.ParseRuntimeCall.Returns.Runtime, f.ParseRuntimeCall.Returns.Err
+}
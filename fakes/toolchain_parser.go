@@ -0,0 +1,35 @@
+package fakes
+
+import (
+	"sync"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+)
+
+type ToolchainParser struct {
+	ParseToolchainCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			WorkingDir string
+			Path       string
+		}
+		Returns struct {
+			Toolchain nodeengine.Toolchain
+			Err       error
+		}
+		Stub func(string, string) (nodeengine.Toolchain, error)
+	}
+}
+
+func (f *ToolchainParser) ParseToolchain(param1 string, param2 string) (nodeengine.Toolchain, error) {
+	f.ParseToolchainCall.mutex.Lock()
+	defer f.ParseToolchainCall.mutex.Unlock()
+	f.ParseToolchainCall.CallCount++
+	f.ParseToolchainCall.Receives.WorkingDir = param1
+	f.ParseToolchainCall.Receives.Path = param2
+	if f.ParseToolchainCall.Stub != nil {
+		return f.ParseToolchainCall.Stub(param1, param2)
+	}
+	return f.ParseToolchainCall.Returns.Toolchain, f.ParseToolchainCall.Returns.Err
+}
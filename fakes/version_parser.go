@@ -1,6 +1,10 @@
 package fakes
 
-import "sync"
+import (
+	"sync"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+)
 
 type VersionParser struct {
 	ParseVersionCall struct {
@@ -10,14 +14,14 @@ type VersionParser struct {
 			Path string
 		}
 		Returns struct {
-			Version string
-			Err     error
+			Result nodeengine.VersionResult
+			Err    error
 		}
-		Stub func(string) (string, error)
+		Stub func(string) (nodeengine.VersionResult, error)
 	}
 }
 
-func (f *VersionParser) ParseVersion(param1 string) (string, error) {
+func (f *VersionParser) ParseVersion(param1 string) (nodeengine.VersionResult, error) {
 	f.ParseVersionCall.mutex.Lock()
 	defer f.ParseVersionCall.mutex.Unlock()
 	f.ParseVersionCall.CallCount++
@@ -25,5 +29,5 @@ func (f *VersionParser) ParseVersion(param1 string) (string, error) {
 	if f.ParseVersionCall.Stub != nil {
 		return f.ParseVersionCall.Stub(param1)
 	}
-	return f.ParseVersionCall.Returns.Version, f.ParseVersionCall.Returns.Err
+	return f.ParseVersionCall.Returns.Result, f.ParseVersionCall.Returns.Err
 }
@@ -0,0 +1,30 @@
+package fakes
+
+import "sync"
+
+type VersionResolver struct {
+	ResolveVersionCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			Version string
+		}
+		Returns struct {
+			Constraint string
+			Ok         bool
+			Err        error
+		}
+		Stub func(string) (string, bool, error)
+	}
+}
+
+func (f *VersionResolver) ResolveVersion(param1 string) (string, bool, error) {
+	f.ResolveVersionCall.mutex.Lock()
+	defer f.ResolveVersionCall.mutex.Unlock()
+	f.ResolveVersionCall.CallCount++
+	f.ResolveVersionCall.Receives.Version = param1
+	if f.ResolveVersionCall.Stub != nil {
+		return f.ResolveVersionCall.Stub(param1)
+	}
+	return f.ResolveVersionCall.Returns.Constraint, f.ResolveVersionCall.Returns.Ok, f.ResolveVersionCall.Returns.Err
+}
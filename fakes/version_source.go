@@ -0,0 +1,53 @@
+package fakes
+
+import (
+	"sync"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+)
+
+type VersionSource struct {
+	FindCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			WorkingDir  string
+			ProjectPath string
+		}
+		Returns struct {
+			Result nodeengine.VersionResult
+			Found  bool
+			Err    error
+		}
+		Stub func(string, string) (nodeengine.VersionResult, bool, error)
+	}
+	NameCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Returns   struct {
+			String string
+		}
+		Stub func() string
+	}
+}
+
+func (f *VersionSource) Find(param1 string, param2 string) (nodeengine.VersionResult, bool, error) {
+	f.FindCall.mutex.Lock()
+	defer f.FindCall.mutex.Unlock()
+	f.FindCall.CallCount++
+	f.FindCall.Receives.WorkingDir = param1
+	f.FindCall.Receives.ProjectPath = param2
+	if f.FindCall.Stub != nil {
+		return f.FindCall.Stub(param1, param2)
+	}
+	return f.FindCall.Returns.Result, f.FindCall.Returns.Found, f.FindCall.Returns.Err
+}
+func (f *VersionSource) Name() string {
+	f.NameCall.mutex.Lock()
+	defer f.NameCall.mutex.Unlock()
+	f.NameCall.CallCount++
+	if f.NameCall.Stub != nil {
+		return f.NameCall.Stub()
+	}
+	return f.NameCall.Returns.String
+}
@@ -14,5 +14,15 @@ func TestUnitNode(t *testing.T) {
 	suite("Detect", testDetect)
 	suite("NvmrcParser", testNvmrcParser)
 	suite("NodeVersionParser", testNodeVersionParser)
+	suite("PackageJSONParser", testPackageJSONParser)
+	suite("ToolVersionsParser", testToolVersionsParser)
+	suite("MiseParser", testMiseParser)
+	suite("VoltaParser", testVoltaParser)
+	suite("DevEnginesParser", testDevEnginesParser)
+	suite("LTSCodenames", testLTSCodenames)
+	suite("AliasResolver", testAliasResolver)
+	suite("BuildpackYMLParser", testBuildpackYMLParser)
+	suite("VersionSource", testVersionSource)
+	suite("Signature", testSignature)
 	suite.Run(t)
 }
@@ -0,0 +1,90 @@
+package nodeengine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LTSCodenames resolves the codenames of Node.js LTS release lines, such as
+// "iron" or "jod", to a version constraint. The codenames are read from the
+// [metadata.lts-codenames] table of the buildpack.toml, which the dependency
+// retrieval tool keeps up to date with the nodejs.org release index.
+type LTSCodenames struct {
+	buildpackTOMLPath string
+}
+
+func NewLTSCodenames(buildpackTOMLPath string) LTSCodenames {
+	return LTSCodenames{
+		buildpackTOMLPath: buildpackTOMLPath,
+	}
+}
+
+// Resolve returns a version constraint matching the release line of the
+// given LTS codename. Like nvm, it also accepts "*" for the newest LTS release
+// line and "-N" for the release line N places before it. The boolean result
+// is false when the codename does not name a known release line.
+func (c LTSCodenames) Resolve(codename string) (string, bool, error) {
+	codenames, err := c.load()
+	if err != nil {
+		return "", false, err
+	}
+
+	offset := -1
+	if codename == "*" {
+		offset = 0
+	} else if relative, ok := strings.CutPrefix(codename, "-"); ok {
+		if n, err := strconv.Atoi(relative); err == nil && n > 0 {
+			offset = n
+		}
+	}
+
+	if offset >= 0 {
+		var majors []int
+		for _, major := range codenames {
+			majors = append(majors, major)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(majors)))
+
+		if offset >= len(majors) {
+			return "", false, nil
+		}
+
+		return fmt.Sprintf("%d.*", majors[offset]), true, nil
+	}
+
+	major, ok := codenames[strings.ToLower(codename)]
+	if !ok {
+		return "", false, nil
+	}
+
+	return fmt.Sprintf("%d.*", major), true, nil
+}
+
+func (c LTSCodenames) load() (map[string]int, error) {
+	var buildpack struct {
+		Metadata struct {
+			LTSCodenames map[string]string `toml:"lts-codenames"`
+		} `toml:"metadata"`
+	}
+
+	_, err := toml.DecodeFile(c.buildpackTOMLPath, &buildpack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LTS codenames: %w", err)
+	}
+
+	codenames := map[string]int{}
+	for codename, line := range buildpack.Metadata.LTSCodenames {
+		major, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LTS codenames: invalid release line %q for %q", line, codename)
+		}
+
+		codenames[strings.ToLower(codename)] = major
+	}
+
+	return codenames, nil
+}
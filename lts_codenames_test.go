@@ -0,0 +1,115 @@
+package nodeengine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLTSCodenames(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path         string
+		ltsCodenames nodeengine.LTSCodenames
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "buildpack.toml")
+		Expect(os.WriteFile(path, []byte(`
+[metadata]
+  [metadata.lts-codenames]
+    hydrogen = "18"
+    iron = "20"
+    Jod = "22"
+`), 0600)).To(Succeed())
+
+		ltsCodenames = nodeengine.NewLTSCodenames(path)
+	})
+
+	it("resolves codenames to their release line", func() {
+		version, ok, err := ltsCodenames.Resolve("iron")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(version).To(Equal("20.*"))
+
+		version, ok, err = ltsCodenames.Resolve("JOD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(version).To(Equal("22.*"))
+	})
+
+	it("resolves * to the newest release line", func() {
+		version, ok, err := ltsCodenames.Resolve("*")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(version).To(Equal("22.*"))
+	})
+
+	it("resolves -N to the release line N places before the newest one", func() {
+		version, ok, err := ltsCodenames.Resolve("-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(version).To(Equal("20.*"))
+
+		version, ok, err = ltsCodenames.Resolve("-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(version).To(Equal("18.*"))
+
+		_, ok, err = ltsCodenames.Resolve("-3")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+
+		_, ok, err = ltsCodenames.Resolve("-0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	it("reports unknown codenames", func() {
+		_, ok, err := ltsCodenames.Resolve("unknown")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	context("when the buildpack.toml has no LTS codenames", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte("[metadata]\n"), 0600)).To(Succeed())
+		})
+
+		it("does not resolve any codename", func() {
+			_, ok, err := ltsCodenames.Resolve("*")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the buildpack.toml cannot be read", func() {
+			it.Before(func() {
+				Expect(os.Remove(path)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, _, err := ltsCodenames.Resolve("iron")
+				Expect(err).To(MatchError(ContainSubstring("failed to read LTS codenames")))
+			})
+		})
+
+		context("when a release line is not a number", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte("[metadata.lts-codenames]\niron = \"twenty\"\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, _, err := ltsCodenames.Resolve("iron")
+				Expect(err).To(MatchError(`failed to read LTS codenames: invalid release line "twenty" for "iron"`))
+			})
+		})
+	})
+}
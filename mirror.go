@@ -0,0 +1,103 @@
+package nodeengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+// distMirrorFromEnvironment returns the URL of the nodejs.org compatible
+// release mirror given by BP_NODE_DIST_MIRROR, or an empty string when it is
+// not set.
+func distMirrorFromEnvironment() (string, error) {
+	mirror := strings.TrimSuffix(os.Getenv("BP_NODE_DIST_MIRROR"), "/")
+	if mirror != "" && !isHTTPURL(mirror) {
+		return "", fmt.Errorf("invalid value for BP_NODE_DIST_MIRROR: %q, expected an http or https URL", mirror)
+	}
+
+	return mirror, nil
+}
+
+// resolveFromMirror returns a dependency for the newest release listed in the
+// index.json of the mirror that satisfies the version requested by the given
+// plan entry and is built for the target platform. The mirror has the layout
+// of https://nodejs.org/dist, and the checksum of the release archive is read
+// from the SHASUMS256.txt file of the release, once its signature has been
+// verified against the given Node.js release keys. The dependency has the
+// deprecation date of the buildpack dependencies on its release line, if
+// there are any. The boolean result is false when no release satisfies the
+// requested version.
+func resolveFromMirror(mirror string, entry packit.BuildpackPlanEntry, dependencies []postal.Dependency, keys ReleaseKeys) (postal.Dependency, bool, error) {
+	version, _ := entry.Metadata["version"].(string)
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return postal.Dependency{}, false, nil
+	}
+
+	content, err := fetch(fmt.Sprintf("%s/index.json", mirror))
+	if err != nil {
+		return postal.Dependency{}, false, err
+	}
+
+	var releases []struct {
+		Version string   `json:"version"`
+		Files   []string `json:"files"`
+	}
+	err = json.Unmarshal(content, &releases)
+	if err != nil {
+		return postal.Dependency{}, false, fmt.Errorf("failed to parse %s/index.json: %w", mirror, err)
+	}
+
+	targetOS, targetArch := targetPlatform()
+	nodeArch := targetArch
+	if nodeArch == "amd64" {
+		nodeArch = "x64"
+	}
+	platform := fmt.Sprintf("%s-%s", targetOS, nodeArch)
+
+	var newest *semver.Version
+	for _, release := range releases {
+		v, err := semver.NewVersion(release.Version)
+		if err != nil || !constraint.Check(v) || !slices.Contains(release.Files, platform) {
+			continue
+		}
+
+		if newest == nil || v.GreaterThan(newest) {
+			newest = v
+		}
+	}
+
+	if newest == nil {
+		return postal.Dependency{}, false, nil
+	}
+
+	filename := fmt.Sprintf("node-v%s-%s.tar.xz", newest, platform)
+	shasumsURI := fmt.Sprintf("%s/v%s/SHASUMS256.txt", mirror, newest)
+	shasums, err := keys.VerifiedSHASUMS(shasumsURI)
+	if err != nil {
+		return postal.Dependency{}, false, err
+	}
+
+	checksum, err := checksumFor(shasums, shasumsURI, filename)
+	if err != nil {
+		return postal.Dependency{}, false, err
+	}
+
+	dependency := nodeDistribution(fmt.Sprintf("%s/v%s/%s", mirror, newest, filename), newest.String(), checksum)
+	dependency.StripComponents = 1
+
+	for _, d := range dependencies {
+		if semver.MustParse(d.Version).Major() == newest.Major() && !d.DeprecationDate.IsZero() {
+			dependency.DeprecationDate = d.DeprecationDate
+			break
+		}
+	}
+
+	return dependency, true, nil
+}
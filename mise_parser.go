@@ -0,0 +1,95 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+type MiseParser struct {
+	aliasResolver AliasResolver
+}
+
+func NewMiseParser(aliasResolver AliasResolver) MiseParser {
+	return MiseParser{
+		aliasResolver: aliasResolver,
+	}
+}
+
+func (p MiseParser) ParseVersion(path string) (VersionResult, error) {
+	var config struct {
+		Tools map[string]interface{} `toml:"tools"`
+	}
+
+	_, err := toml.DecodeFile(path, &config)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return VersionResult{}, nil
+		}
+		return VersionResult{}, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+
+	var names []string
+	for name := range config.Tools {
+		if isNodeTool(name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return VersionResult{}, nil
+	}
+
+	// Prefer "node" over "nodejs" when a file happens to declare both.
+	sort.Strings(names)
+
+	versions, err := p.toolVersions(config.Tools[names[0]])
+	if err != nil {
+		return VersionResult{}, fmt.Errorf("invalid tool specification in %s: %w", filepath.Base(path), err)
+	}
+
+	version, err := formatToolVersions(versions, filepath.Base(path), p.aliasResolver)
+	if err != nil {
+		return VersionResult{}, err
+	}
+
+	return VersionResult{
+		Raw:        strings.Join(versions, " "),
+		Constraint: version,
+		Path:       path,
+	}, nil
+}
+
+// toolVersions returns the versions given for a tool, which mise allows to be
+// written as a single version, a list of fallback versions, or a table with a
+// version key.
+func (p MiseParser) toolVersions(tool interface{}) ([]string, error) {
+	switch tool := tool.(type) {
+	case string:
+		return []string{tool}, nil
+
+	case []interface{}:
+		var versions []string
+		for _, item := range tool {
+			itemVersions, err := p.toolVersions(item)
+			if err != nil {
+				return nil, err
+			}
+			versions = append(versions, itemVersions...)
+		}
+		return versions, nil
+
+	case map[string]interface{}:
+		version, ok := tool["version"].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a version key in %v", tool)
+		}
+		return []string{version}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value %v", tool)
+}
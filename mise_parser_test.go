@@ -0,0 +1,132 @@
+package nodeengine_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testMiseParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser nodeengine.MiseParser
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "mise.toml")
+
+		buildpackTOMLPath := filepath.Join(t.TempDir(), "buildpack.toml")
+		Expect(os.WriteFile(buildpackTOMLPath, []byte(`
+[metadata.lts-codenames]
+  gallium = "16"
+  hydrogen = "18"
+  iron = "20"
+  jod = "22"
+`), 0600)).To(Succeed())
+
+		parser = nodeengine.NewMiseParser(nodeengine.NewAliasResolver(nodeengine.NewLTSCodenames(buildpackTOMLPath)))
+	})
+
+	it("returns a version constraint", func() {
+		testCases := map[string]string{
+			`node = "20.11.1"`:                  "20.11.1",
+			`nodejs = "20"`:                     "20",
+			`node = "v20.11.1"`:                 "20.11.1",
+			`node = ["20.11.1", "18"]`:          "20.11.1 || 18",
+			`node = { version = "22" }`:         "22",
+			`node = [{ version = "22" }, "20"]`: "22 || 20",
+			`node = "lts"`:                      "22.*",
+			`node = "lts/iron"`:                 "20.*",
+			`node = "latest"`:                   "*",
+			`python = "3.12"`:                   "",
+			"python = \"3.12\"\nnode = \"20\"":  "20",
+			"nodejs = \"18\"\nnode = \"20\"":    "20",
+			`"node" = "22.x"`:                   "22.x",
+			`node = "23.0.0-rc.1"`:              "23.0.0-rc.1",
+		}
+
+		for input, output := range testCases {
+			err := os.WriteFile(path, []byte("[tools]\n"+input), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Constraint).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
+		}
+	})
+
+	it("reports where the version was found", func() {
+		Expect(os.WriteFile(path, []byte("[tools]\nnode = [\"lts\", \"20\"]\n"), 0644)).To(Succeed())
+
+		result, err := parser.ParseVersion(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(nodeengine.VersionResult{
+			Raw:        "lts 20",
+			Constraint: "22.* || 20",
+			Path:       path,
+		}))
+	})
+
+	context("when the file does not have a tools table", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte("[env]\nNODE_ENV = \"production\"\n"), 0644)).To(Succeed())
+		})
+
+		it("returns an empty result", func() {
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(nodeengine.VersionResult{}))
+		})
+	})
+
+	context("when the file does not exist", func() {
+		it("returns an empty result", func() {
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(nodeengine.VersionResult{}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the file is not valid TOML", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte("[tools"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse mise.toml")))
+			})
+		})
+
+		context("when the node tool has an unsupported value", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte("[tools]\nnode = 20\n"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError(ContainSubstring("invalid tool specification in mise.toml")))
+			})
+		})
+
+		context("when the node tool has a malformed version", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte("[tools]\nnode = \"prefix:20\"\n"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError("invalid version constraint specified in mise.toml: \"prefix:20\""))
+			})
+		})
+	})
+}
@@ -1,44 +1,59 @@
 package nodeengine
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"strings"
-
-	"github.com/Masterminds/semver/v3"
+	"unicode"
 )
 
-type NodeVersionParser struct{}
+type NodeVersionParser struct {
+	aliasResolver AliasResolver
+}
 
-func NewNodeVersionParser() NodeVersionParser {
-	return NodeVersionParser{}
+func NewNodeVersionParser(aliasResolver AliasResolver) NodeVersionParser {
+	return NodeVersionParser{
+		aliasResolver: aliasResolver,
+	}
 }
 
-func (p NodeVersionParser) ParseVersion(path string) (string, error) {
+func (p NodeVersionParser) ParseVersion(path string) (VersionResult, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", nil
+			return VersionResult{}, nil
 		}
-		return "", err
+		return VersionResult{}, err
 	}
 
 	version, err := p.validateNodeVersion(string(content))
 	if err != nil {
-		return "", err
+		return VersionResult{}, err
 	}
 
-	return version, nil
+	// The version is reported on the first line that is not blank.
+	leading := len(content) - len(bytes.TrimLeftFunc(content, unicode.IsSpace))
+
+	return VersionResult{
+		Raw:        strings.TrimSpace(string(content)),
+		Constraint: version,
+		Path:       path,
+		Line:       bytes.Count(content[:leading], []byte("\n")) + 1,
+	}, nil
 }
 
 func (p NodeVersionParser) validateNodeVersion(content string) (string, error) {
 	content = strings.TrimSpace(strings.ToLower(content))
 
-	content = strings.TrimPrefix(content, "v")
+	version, ok, err := p.aliasResolver.ResolveVersion(content)
+	if err != nil {
+		return "", err
+	}
 
-	if _, err := semver.NewConstraint(content); err != nil {
-		return "", fmt.Errorf("invalid version constraint specified in .node-version: %q", content)
+	if !ok {
+		return "", fmt.Errorf("invalid version constraint specified in .node-version: %q", strings.TrimPrefix(content, "v"))
 	}
 
-	return content, nil
+	return version, nil
 }
@@ -3,6 +3,7 @@ package nodeengine_test
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
@@ -26,7 +27,15 @@ func testNodeVersionParser(t *testing.T, context spec.G, it spec.S) {
 		path = file.Name()
 		Expect(file.Close()).To(Succeed())
 
-		parser = nodeengine.NewNodeVersionParser()
+		buildpackTOMLPath := filepath.Join(t.TempDir(), "buildpack.toml")
+		Expect(os.WriteFile(buildpackTOMLPath, []byte(`
+[metadata.lts-codenames]
+  hydrogen = "18"
+  iron = "20"
+  jod = "22"
+`), 0600)).To(Succeed())
+
+		parser = nodeengine.NewNodeVersionParser(nodeengine.NewAliasResolver(nodeengine.NewLTSCodenames(buildpackTOMLPath)))
 	})
 
 	it.After(func() {
@@ -35,30 +44,47 @@ func testNodeVersionParser(t *testing.T, context spec.G, it spec.S) {
 
 	it("returns a version constraint", func() {
 		testCases := map[string]string{
-			"10.2":    "10.2",
-			"10.2.3":  "10.2.3",
-			"v10.2.3": "10.2.3",
+			"10.2":        "10.2",
+			"10.2.3":      "10.2.3",
+			"v10.2.3":     "10.2.3",
+			"lts/*":       "22.*",
+			"lts/iron":    "20.*",
+			"node":        "*",
+			"23.0.0-rc.1": "23.0.0-rc.1",
 		}
 
 		for input, output := range testCases {
 			err := os.WriteFile(path, []byte(input), 0644)
 			Expect(err).NotTo(HaveOccurred())
 
-			version, err := parser.ParseVersion(path)
+			result, err := parser.ParseVersion(path)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(version).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
+			Expect(result.Constraint).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
 		}
 	})
 
+	it("reports where the version was found", func() {
+		Expect(os.WriteFile(path, []byte("\n  v20.11.1\n"), 0644)).To(Succeed())
+
+		result, err := parser.ParseVersion(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(nodeengine.VersionResult{
+			Raw:        "v20.11.1",
+			Constraint: "20.11.1",
+			Path:       path,
+			Line:       2,
+		}))
+	})
+
 	context("when the .node-version file does not exist", func() {
 		it.Before(func() {
 			Expect(os.RemoveAll(path)).To(Succeed())
 		})
 
-		it("returns an empty version", func() {
-			version, err := parser.ParseVersion(path)
+		it("returns an empty result", func() {
+			result, err := parser.ParseVersion(path)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(version).To(BeEmpty())
+			Expect(result).To(Equal(nodeengine.VersionResult{}))
 		})
 	})
 
@@ -75,27 +101,15 @@ func testNodeVersionParser(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
-		context("when the .node-version contains a value prefixed with `lts/`", func() {
-			it.Before(func() {
-				err := os.WriteFile(path, []byte("lts/*"), 0644)
-				Expect(err).NotTo(HaveOccurred())
-			})
-
-			it("returns an error", func() {
-				_, err := parser.ParseVersion(path)
-				Expect(err).To(MatchError("invalid version constraint specified in .node-version: \"lts/*\""))
-			})
-		})
-
-		context("when the .node-version contains the value `node`", func() {
+		context("when the .node-version contains an unknown LTS codename", func() {
 			it.Before(func() {
-				err := os.WriteFile(path, []byte("node"), 0644)
+				err := os.WriteFile(path, []byte("lts/unknown"), 0644)
 				Expect(err).NotTo(HaveOccurred())
 			})
 
 			it("returns an error", func() {
 				_, err := parser.ParseVersion(path)
-				Expect(err).To(MatchError("invalid version constraint specified in .node-version: \"node\""))
+				Expect(err).To(MatchError("invalid version constraint specified in .node-version: \"lts/unknown\""))
 			})
 		})
 	})
@@ -0,0 +1,162 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// unavailableNpm reports whether the given npm plan entry, which no npm
+// dependency satisfies, falls back to the npm bundled with Node. Only a
+// version requested by BP_NPM_VERSION has to be installed.
+func unavailableNpm(entry packit.BuildpackPlanEntry, logger scribe.Emitter) bool {
+	if source, _ := entry.Metadata["version-source"].(string); source == "BP_NPM_VERSION" {
+		return false
+	}
+
+	version, _ := entry.Metadata["version"].(string)
+	logger.Process("WARNING: npm version %q requested by %s is not available.", version, planEntryLabel(entry))
+	logger.Subprocess("Using the npm bundled with Node instead, set BP_NPM_VERSION to require a version.")
+	logger.Break()
+
+	return true
+}
+
+// npmVersionSourcePriorities lists the version sources of npm build plan
+// entries from highest to lowest priority.
+var npmVersionSourcePriorities = []interface{}{
+	"BP_NPM_VERSION",
+	PackageJSONSource,
+}
+
+// npmVersionRequirement returns the npm version requested by BP_NPM_VERSION
+// or, when it is not set, by the engines.npm field of the package.json of the
+// project. The boolean result is false when npm is not pinned. Only
+// BP_NPM_VERSION is a hard requirement: an engines.npm field that is not a
+// valid constraint is ignored, and the npm bundled with Node is used.
+func npmVersionRequirement(workingDir, projectPath string, logger scribe.Emitter) (BuildPlanMetadata, bool, error) {
+	if version := strings.TrimSpace(os.Getenv("BP_NPM_VERSION")); version != "" {
+		if _, err := semver.NewConstraint(version); err != nil {
+			return BuildPlanMetadata{}, false, fmt.Errorf("invalid version constraint specified in BP_NPM_VERSION: %q", version)
+		}
+
+		return BuildPlanMetadata{Version: version, VersionSource: "BP_NPM_VERSION"}, true, nil
+	}
+
+	path := filepath.Join(projectPath, PackageJSONSource)
+	err := checkVersionFile(Npm, workingDir, path)
+	if err != nil {
+		return BuildPlanMetadata{}, false, err
+	}
+
+	pkg, err := parsePackageJSON(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BuildPlanMetadata{}, false, nil
+		}
+		return BuildPlanMetadata{}, false, err
+	}
+
+	version := strings.TrimSpace(strings.ToLower(pkg.Engines.Npm))
+	if version == "" {
+		return BuildPlanMetadata{}, false, nil
+	}
+
+	if _, err := semver.NewConstraint(version); err != nil {
+		logger.Debug.Subprocess("Ignoring invalid version constraint specified in package.json engines.npm: %q", version)
+		return BuildPlanMetadata{}, false, nil
+	}
+
+	return BuildPlanMetadata{Version: version, VersionSource: PackageJSONSource}, true, nil
+}
+
+// npmEntries returns the npm build plan entries that request a version.
+// Buildpacks that require npm to install packages do not request one, and
+// are satisfied by the npm bundled with Node.
+func npmEntries(entries []packit.BuildpackPlanEntry) []packit.BuildpackPlanEntry {
+	var versioned []packit.BuildpackPlanEntry
+	for _, entry := range entries {
+		if version, _ := entry.Metadata["version"].(string); entry.Name == Npm && version != "" {
+			versioned = append(versioned, entry)
+		}
+	}
+	return versioned
+}
+
+// installNpm delivers the given npm dependency into the npm layer, unless the
+// layer already holds it. The lifecycle puts the bin directories of the layers
+// on the PATH in the order of their names, so the npm layer comes in front of
+// the node layer and shadows the npm bundled with Node.
+func installNpm(context packit.BuildContext, dependency postal.Dependency, dependencyManager DependencyManager, sbomGenerator SBOMGenerator, build, launch, sbomDisabled bool, logger scribe.Emitter, clock chronos.Clock) (packit.Layer, error) {
+	npmLayer, err := context.Layers.Get(Npm)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if IsLayerReusable(npmLayer, dependency.Checksum, build, launch, logger) {
+		logger.Process("Reusing cached layer %s", npmLayer.Path)
+		logger.Break()
+
+		npmLayer.Launch, npmLayer.Build, npmLayer.Cache = launch, build, build
+		return npmLayer, nil
+	}
+
+	npmLayer, err = npmLayer.Reset()
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	npmLayer.Launch, npmLayer.Build, npmLayer.Cache = launch, build, build
+	npmLayer.Metadata = map[string]interface{}{
+		DepKey:    dependency.Checksum,
+		BuildKey:  build,
+		LaunchKey: launch,
+	}
+
+	logger.Subprocess("Installing npm %s", dependency.Version)
+	duration, err := clock.Measure(func() error {
+		return dependencyManager.Deliver(dependency, context.CNBPath, npmLayer.Path, context.Platform.Path)
+	})
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	if sbomDisabled {
+		logger.Subprocess("Skipping SBOM generation for npm")
+		logger.Break()
+		return npmLayer, nil
+	}
+
+	logger.GeneratingSBOM(npmLayer.Path)
+	var sbomContent sbom.SBOM
+	duration, err = clock.Measure(func() error {
+		sbomContent, err = sbomGenerator.GenerateFromDependency(dependency, npmLayer.Path)
+		return err
+	})
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
+	npmLayer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	return npmLayer, nil
+}
@@ -1,101 +1,87 @@
 package nodeengine
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"strings"
-
-	"github.com/Masterminds/semver/v3"
 )
 
-var (
-	lts = map[string]int{
-		"argon":    4,
-		"boron":    6,
-		"carbon":   8,
-		"dubnium":  10,
-		"erbium":   12,
-		"fermium":  14,
-		"gallium":  16,
-		"hydrogen": 18,
-		"iron":     20,
-	}
-)
+// NvmrcError describes the line of an .nvmrc file that could not be parsed.
+type NvmrcError struct {
+	Line    int
+	Content string
+	Reason  string
+}
+
+func (e NvmrcError) Error() string {
+	return fmt.Sprintf("%s specified in .nvmrc: %q (line %d)", e.Reason, e.Content, e.Line)
+}
 
-type NvmrcParser struct{}
+type NvmrcParser struct {
+	aliasResolver AliasResolver
+}
 
-func NewNvmrcParser() NvmrcParser {
-	return NvmrcParser{}
+func NewNvmrcParser(aliasResolver AliasResolver) NvmrcParser {
+	return NvmrcParser{
+		aliasResolver: aliasResolver,
+	}
 }
 
-func (p NvmrcParser) ParseVersion(path string) (string, error) {
+// ParseVersion returns the version constraint given in the .nvmrc file at
+// the given path. Like nvm, it ignores blank lines and "#" comments, and
+// expects the remaining content to be a single version or alias.
+func (p NvmrcParser) ParseVersion(path string) (VersionResult, error) {
 	nvmrcContents, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", nil
+			return VersionResult{}, nil
 		}
-		return "", err
-	}
-
-	nvmrcVersion, err := p.validateNvmrc(string(nvmrcContents))
-	if err != nil {
-		return "", err
+		return VersionResult{}, err
 	}
 
-	// TODO
-	// if nvmrcVersion == Node {
-	// 	logger.Info(".nvmrc specified latest node version, this will be selected from versions available in buildpack.toml")
-	// }
-	//
-	// if strings.HasPrefix(nvmrcVersion, "lts") {
-	// 	logger.Info(".nvmrc specified an lts version, this will be selected from versions available in buildpack.toml")
-	// }
-
-	return p.formatNvmrcContent(nvmrcVersion), nil
-}
+	var (
+		version    string
+		lineNumber int
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(nvmrcContents))
+	for i := 1; scanner.Scan(); i++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
 
-func (p NvmrcParser) validateNvmrc(content string) (string, error) {
-	content = strings.TrimSpace(strings.ToLower(content))
+		if lineNumber != 0 {
+			return VersionResult{}, NvmrcError{Line: i, Content: line, Reason: "more than one version"}
+		}
 
-	if content == "lts/*" || content == Node {
-		return content, nil
+		version, lineNumber = line, i
 	}
 
-	for key := range lts {
-		if content == strings.ToLower("lts/"+key) {
-			return content, nil
-		}
+	if err := scanner.Err(); err != nil {
+		return VersionResult{}, err
 	}
 
-	content = strings.TrimPrefix(content, "v")
-
-	if _, err := semver.NewConstraint(content); err != nil {
-		return "", fmt.Errorf("invalid version constraint specified in .nvmrc: %q", content)
+	if version == "" {
+		return VersionResult{}, nil
 	}
 
-	return content, nil
-}
-
-func (p NvmrcParser) formatNvmrcContent(version string) string {
-	if version == Node {
-		return "*"
+	constraint, ok, err := p.aliasResolver.ResolveVersion(version)
+	if err != nil {
+		return VersionResult{}, err
 	}
 
-	if strings.HasPrefix(version, "lts") {
-		ltsName := strings.SplitN(version, "/", 2)[1]
-		if ltsName == "*" {
-			var maxVersion int
-			for _, versionValue := range lts {
-				if maxVersion < versionValue {
-					maxVersion = versionValue
-				}
-			}
-
-			return fmt.Sprintf("%d.*", maxVersion)
-		}
-
-		return fmt.Sprintf("%d.*", lts[ltsName])
+	if !ok {
+		return VersionResult{}, NvmrcError{Line: lineNumber, Content: version, Reason: "invalid version constraint"}
 	}
 
-	return version
+	return VersionResult{
+		Raw:        version,
+		Constraint: constraint,
+		Path:       path,
+		Line:       lineNumber,
+	}, nil
 }
@@ -3,6 +3,7 @@ package nodeengine_test
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
@@ -26,7 +27,23 @@ func testNvmrcParser(t *testing.T, context spec.G, it spec.S) {
 		path = file.Name()
 		Expect(file.Close()).To(Succeed())
 
-		parser = nodeengine.NewNvmrcParser()
+		buildpackTOMLPath := filepath.Join(t.TempDir(), "buildpack.toml")
+		Expect(os.WriteFile(buildpackTOMLPath, []byte(`
+[metadata.lts-codenames]
+  argon = "4"
+  boron = "6"
+  carbon = "8"
+  dubnium = "10"
+  erbium = "12"
+  fermium = "14"
+  gallium = "16"
+  hydrogen = "18"
+  iron = "20"
+  jod = "22"
+  krypton = "24"
+`), 0600)).To(Succeed())
+
+		parser = nodeengine.NewNvmrcParser(nodeengine.NewAliasResolver(nodeengine.NewLTSCodenames(buildpackTOMLPath)))
 	})
 
 	it.After(func() {
@@ -35,50 +52,89 @@ func testNvmrcParser(t *testing.T, context spec.G, it spec.S) {
 
 	it("returns a version constraint", func() {
 		testCases := map[string]string{
-			"10":           "10",
-			"10.2":         "10.2",
-			"v10":          "10",
-			"10.2.3":       "10.2.3",
-			"v10.2.3":      "10.2.3",
-			"10.1.1":       "10.1.1",
-			"10.1.*":       "10.1.*",
-			"10.*":         "10.*",
-			"lts/*":        "20.*",
-			"lts/argon":    "4.*",
-			"lts/boron":    "6.*",
-			"lts/carbon":   "8.*",
-			"lts/dubnium":  "10.*",
-			"lts/erbium":   "12.*",
-			"lts/fermium":  "14.*",
-			"lts/gallium":  "16.*",
-			"lts/hydrogen": "18.*",
-			"lts/iron":     "20.*",
-			"node":         "*",
+			"10":                           "10",
+			"10.2":                         "10.2",
+			"v10":                          "10",
+			"10.2.3":                       "10.2.3",
+			"v10.2.3":                      "10.2.3",
+			"10.1.1":                       "10.1.1",
+			"10.1.*":                       "10.1.*",
+			"10.*":                         "10.*",
+			"lts/*":                        "24.*",
+			"lts/argon":                    "4.*",
+			"lts/boron":                    "6.*",
+			"lts/carbon":                   "8.*",
+			"lts/dubnium":                  "10.*",
+			"lts/erbium":                   "12.*",
+			"lts/fermium":                  "14.*",
+			"lts/gallium":                  "16.*",
+			"lts/hydrogen":                 "18.*",
+			"lts/iron":                     "20.*",
+			"lts/jod":                      "22.*",
+			"lts/krypton":                  "24.*",
+			"LTS/Krypton":                  "24.*",
+			"node":                         "*",
+			"stable":                       "*",
+			"current":                      "*",
+			"latest":                       "*",
+			"lts/-1":                       "22.*",
+			"lts/-2":                       "20.*",
+			"lts/-10":                      "4.*",
+			"20 # pinned for production\n": "20",
+			"\n\n  lts/iron  \n\n":         "20.*",
+			"# The version of Node.js used by the project\n22\n": "22",
+			"# no version\n\n": "",
+			"v23.0.0-rc.1":     "23.0.0-rc.1",
 		}
 
 		for input, output := range testCases {
 			err := os.WriteFile(path, []byte(input), 0644)
 			Expect(err).NotTo(HaveOccurred())
 
-			version, err := parser.ParseVersion(path)
+			result, err := parser.ParseVersion(path)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(version).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
+			Expect(result.Constraint).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
 		}
 	})
 
+	it("reports where the version was found", func() {
+		Expect(os.WriteFile(path, []byte("# pinned for production\n\nLTS/Iron # comment\n"), 0644)).To(Succeed())
+
+		result, err := parser.ParseVersion(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(nodeengine.VersionResult{
+			Raw:        "LTS/Iron",
+			Constraint: "20.*",
+			Path:       path,
+			Line:       3,
+		}))
+	})
+
 	context("when the .nvmrc file does not exist", func() {
 		it.Before(func() {
 			Expect(os.RemoveAll(path)).To(Succeed())
 		})
 
-		it("returns an empty version", func() {
-			version, err := parser.ParseVersion(path)
+		it("returns an empty result", func() {
+			result, err := parser.ParseVersion(path)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(version).To(BeEmpty())
+			Expect(result).To(Equal(nodeengine.VersionResult{}))
 		})
 	})
 
 	context("failure cases", func() {
+		context("when the .nvmrc contains an unknown LTS codename", func() {
+			it.Before(func() {
+				err := os.WriteFile(path, []byte("lts/unknown"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError("invalid version constraint specified in .nvmrc: \"lts/unknown\" (line 1)"))
+			})
+		})
+
 		context("when the .nvmrc contains a malformed semver number", func() {
 			it.Before(func() {
 				err := os.WriteFile(path, []byte("1.2.this is not a number"), 0644)
@@ -87,7 +143,47 @@ func testNvmrcParser(t *testing.T, context spec.G, it spec.S) {
 
 			it("returns an error", func() {
 				_, err := parser.ParseVersion(path)
-				Expect(err).To(MatchError("invalid version constraint specified in .nvmrc: \"1.2.this is not a number\""))
+				Expect(err).To(MatchError("invalid version constraint specified in .nvmrc: \"1.2.this is not a number\" (line 1)"))
+			})
+		})
+
+		context("when the .nvmrc contains a relative LTS alias beyond the known release lines", func() {
+			it.Before(func() {
+				err := os.WriteFile(path, []byte("lts/-11"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError("invalid version constraint specified in .nvmrc: \"lts/-11\" (line 1)"))
+			})
+		})
+
+		context("when the .nvmrc contains an invalid version after comments", func() {
+			it.Before(func() {
+				err := os.WriteFile(path, []byte("# comment\n\niojs # unsupported\n"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("returns an error pointing to the line", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError(nodeengine.NvmrcError{
+					Line:    3,
+					Content: "iojs",
+					Reason:  "invalid version constraint",
+				}))
+			})
+		})
+
+		context("when the .nvmrc contains more than one version", func() {
+			it.Before(func() {
+				err := os.WriteFile(path, []byte("20\n# fallback\n18\n"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("returns an error pointing to the line", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError("more than one version specified in .nvmrc: \"18\" (line 3)"))
 			})
 		})
 	})
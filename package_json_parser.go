@@ -0,0 +1,84 @@
+package nodeengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+type packageJSON struct {
+	Engines struct {
+		Node string `json:"node"`
+		Npm  string `json:"npm"`
+	} `json:"engines"`
+	Volta struct {
+		Node    string `json:"node"`
+		Npm     string `json:"npm"`
+		Yarn    string `json:"yarn"`
+		Extends string `json:"extends"`
+	} `json:"volta"`
+	DevEngines struct {
+		Runtime json.RawMessage `json:"runtime"`
+	} `json:"devEngines"`
+	Workspaces     json.RawMessage `json:"workspaces"`
+	PackageManager string          `json:"packageManager"`
+}
+
+type PackageJSONParser struct{}
+
+func NewPackageJSONParser() PackageJSONParser {
+	return PackageJSONParser{}
+}
+
+func (p PackageJSONParser) ParseVersion(path string) (VersionResult, error) {
+	pkg, err := parsePackageJSON(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return VersionResult{}, nil
+		}
+		return VersionResult{}, err
+	}
+
+	if strings.TrimSpace(pkg.Engines.Node) == "" {
+		return VersionResult{}, nil
+	}
+
+	version, err := p.validateEnginesNode(pkg.Engines.Node)
+	if err != nil {
+		return VersionResult{}, err
+	}
+
+	return VersionResult{
+		Raw:        pkg.Engines.Node,
+		Constraint: version,
+		Path:       path,
+	}, nil
+}
+
+func (p PackageJSONParser) validateEnginesNode(content string) (string, error) {
+	content = strings.TrimSpace(strings.ToLower(content))
+
+	if _, err := semver.NewConstraint(content); err != nil {
+		return "", fmt.Errorf("invalid version constraint specified in package.json engines.node: %q", content)
+	}
+
+	return content, nil
+}
+
+func parsePackageJSON(path string) (packageJSON, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return packageJSON{}, err
+	}
+
+	var pkg packageJSON
+	err = json.Unmarshal(content, &pkg)
+	if err != nil {
+		return packageJSON{}, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	return pkg, nil
+}
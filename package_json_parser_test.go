@@ -0,0 +1,113 @@
+package nodeengine_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPackageJSONParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser nodeengine.PackageJSONParser
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "package.json")
+
+		parser = nodeengine.NewPackageJSONParser()
+	})
+
+	it("returns a version constraint", func() {
+		testCases := map[string]string{
+			"18":                       "18",
+			"18.2.3":                   "18.2.3",
+			"v18.2.3":                  "v18.2.3",
+			"^20":                      "^20",
+			"~20.11":                   "~20.11",
+			">=18 <21":                 ">=18 <21",
+			">= 18":                    ">= 18",
+			"^20 || ^22":               "^20 || ^22",
+			"20.x":                     "20.x",
+			"20.X.x":                   "20.x.x",
+			"x":                        "x",
+			"*":                        "*",
+			"18 - 20":                  "18 - 20",
+			">=18.0.0 <19.0.0 || >=20": ">=18.0.0 <19.0.0 || >=20",
+			">=23.0.0-rc.0":            ">=23.0.0-rc.0",
+		}
+
+		for input, output := range testCases {
+			err := os.WriteFile(path, []byte(fmt.Sprintf(`{"engines": {"node": %q}}`, input)), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Constraint).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
+		}
+	})
+
+	it("reports where the version was found", func() {
+		Expect(os.WriteFile(path, []byte(`{"engines": {"node": "20.X"}}`), 0644)).To(Succeed())
+
+		result, err := parser.ParseVersion(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(nodeengine.VersionResult{
+			Raw:        "20.X",
+			Constraint: "20.x",
+			Path:       path,
+		}))
+	})
+
+	context("when the package.json file does not exist", func() {
+		it("returns an empty result", func() {
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(nodeengine.VersionResult{}))
+		})
+	})
+
+	context("when the package.json file does not specify engines.node", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte(`{"engines": {"npm": "10"}}`), 0644)).To(Succeed())
+		})
+
+		it("returns an empty result", func() {
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(nodeengine.VersionResult{}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the package.json is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`%%%`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse package.json")))
+			})
+		})
+
+		context("when engines.node contains an invalid range", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`{"engines": {"node": "lts"}}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError("invalid version constraint specified in package.json engines.node: \"lts\""))
+			})
+		})
+	})
+}
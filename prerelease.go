@@ -0,0 +1,41 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// prereleasePattern matches the start of a pre-release suffix, like the
+// "-rc.1" of "23.0.0-rc.1". Hyphen ranges like "20 - 22" are surrounded by
+// spaces and do not match.
+var prereleasePattern = regexp.MustCompile(`\d-[0-9A-Za-z]`)
+
+// prereleaseAllowed reads whether pre-release versions of Node may be
+// installed from BP_NODE_ALLOW_PRERELEASE, which defaults to false.
+func prereleaseAllowed() (bool, error) {
+	if allowStr, ok := os.LookupEnv("BP_NODE_ALLOW_PRERELEASE"); ok {
+		allow, err := strconv.ParseBool(allowStr)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse BP_NODE_ALLOW_PRERELEASE value %s: %w", allowStr, err)
+		}
+		return allow, nil
+	}
+	return false, nil
+}
+
+// checkPrerelease returns an error when the version requested by the given
+// plan entry names a pre-release and pre-releases are not allowed. Version
+// constraints only match pre-releases when they name one, so requirements
+// like "23.*" never select a release candidate.
+func checkPrerelease(entry packit.BuildpackPlanEntry, allowed bool) error {
+	version, _ := entry.Metadata["version"].(string)
+	if allowed || !prereleasePattern.MatchString(version) {
+		return nil
+	}
+
+	return fmt.Errorf("pre-release version %q of node requested by %s is not allowed: set BP_NODE_ALLOW_PRERELEASE=true to install it", version, planEntryLabel(entry))
+}
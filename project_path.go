@@ -0,0 +1,32 @@
+package nodeengine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// resolvesWithin reports whether the given path is inside of dir once the
+// symlinks of both have been resolved, so that a symlink cannot be used to
+// read files from outside of the application. A path that does not exist
+// only needs to be lexically inside of dir, since there is nothing to read.
+func resolvesWithin(dir, path string) (bool, error) {
+	if !isWithin(dir, path) {
+		return false, nil
+	}
+
+	resolvedPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false, err
+	}
+
+	return isWithin(resolvedDir, resolvedPath), nil
+}
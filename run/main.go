@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 
 	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
 	"github.com/paketo-buildpacks/packit/v2"
@@ -20,8 +21,27 @@ func (f Generator) GenerateFromDependency(dependency postal.Dependency, path str
 }
 
 func main() {
-	nvmrcParser := nodeengine.NewNvmrcParser()
-	nodeVersionParser := nodeengine.NewNodeVersionParser()
+	aliasResolver := nodeengine.NewAliasResolver(nodeengine.NewLTSCodenames(filepath.Join(os.Getenv("CNB_BUILDPACK_DIR"), "buildpack.toml")))
+	nvmrcParser := nodeengine.NewNvmrcParser(aliasResolver)
+	buildpackYMLParser := nodeengine.NewBuildpackYMLParser(aliasResolver)
+	nodeVersionParser := nodeengine.NewNodeVersionParser(aliasResolver)
+	packageJSONParser := nodeengine.NewPackageJSONParser()
+	toolVersionsParser := nodeengine.NewToolVersionsParser(aliasResolver)
+	miseParser := nodeengine.NewMiseParser(aliasResolver)
+	voltaParser := nodeengine.NewVoltaParser()
+	devEnginesParser := nodeengine.NewDevEnginesParser()
+	versionSources := nodeengine.NewVersionSourceRegistry(
+		nodeengine.NewFileVersionSource(nodeengine.NvmrcSource, ".nvmrc", nvmrcParser),
+		nodeengine.NewFileVersionSource(nodeengine.BuildpackYMLSource, "buildpack.yml", buildpackYMLParser),
+		nodeengine.NewEnvironmentVersionSource("BP_NODE_VERSION", aliasResolver),
+		nodeengine.NewFileVersionSource(nodeengine.NodeVersionSource, ".node-version", nodeVersionParser),
+		nodeengine.NewFileVersionSource(nodeengine.PackageJSONSource, "package.json", packageJSONParser),
+		nodeengine.NewVoltaVersionSource(voltaParser),
+		nodeengine.NewDevEnginesVersionSource(devEnginesParser),
+		nodeengine.NewFileVersionSource(nodeengine.ToolVersionsSource, ".tool-versions", toolVersionsParser),
+		nodeengine.NewFileVersionSource(nodeengine.MiseSource, "mise.toml", miseParser),
+		nodeengine.NewFileVersionSource(nodeengine.DotMiseSource, ".mise.toml", miseParser),
+	)
 	logEmitter := scribe.NewEmitter(os.Stdout).WithLevel(os.Getenv("BP_LOG_LEVEL"))
 	entryResolver := draft.NewPlanner()
 	dependencyManager := postal.NewService(cargo.NewTransport())
@@ -29,8 +49,8 @@ func main() {
 
 	packit.Run(
 		nodeengine.Detect(
-			nvmrcParser,
-			nodeVersionParser,
+			versionSources,
+			logEmitter,
 		),
 		nodeengine.Build(
 			entryResolver,
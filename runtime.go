@@ -0,0 +1,158 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/chronos"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// runtimeSlim reads whether the application is launched with the slim Node
+// runtime from BP_NODE_RUNTIME_SLIM. It defaults to true, unless a plan entry
+// requires npm.
+func runtimeSlim(entries []packit.BuildpackPlanEntry) (bool, error) {
+	if slimStr, ok := os.LookupEnv("BP_NODE_RUNTIME_SLIM"); ok {
+		slim, err := strconv.ParseBool(slimStr)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse BP_NODE_RUNTIME_SLIM value %s: %w", slimStr, err)
+		}
+		return slim, nil
+	}
+
+	for _, entry := range entries {
+		if entry.Name == Npm {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// runtimeRecordKeys lists the node layer metadata keys that record how the
+// version was selected. They are copied to the node-runtime layer, so they can
+// be inspected on the image when the node layer is not a launch layer.
+var runtimeRecordKeys = []string{DecisionTraceKey, FallbackKey, PinnedVersionKey}
+
+// installRuntime copies the node binary out of the node layer into the
+// node-runtime launch layer, unless the layer already holds the given
+// dependency. The layer is configured like the node layer would be at launch,
+// so the npm, corepack, headers and docs of the distribution stay in the node
+// layer, which is only available during the build.
+func installRuntime(context packit.BuildContext, nodeLayer packit.Layer, dependency postal.Dependency, sbomGenerator SBOMGenerator, sbomDisabled bool, logger scribe.Emitter, clock chronos.Clock) (packit.Layer, error) {
+	runtimeLayer, err := context.Layers.Get(NodeRuntime)
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	if IsLayerReusable(runtimeLayer, dependency.Checksum, false, true, logger) {
+		logger.Process("Reusing cached layer %s", runtimeLayer.Path)
+		logger.Break()
+
+		runtimeLayer.Launch, runtimeLayer.Build, runtimeLayer.Cache = true, false, false
+		copyRuntimeRecords(nodeLayer, runtimeLayer)
+		return configureRuntime(context, runtimeLayer, logger), nil
+	}
+
+	runtimeLayer, err = runtimeLayer.Reset()
+	if err != nil {
+		return packit.Layer{}, err
+	}
+
+	runtimeLayer.Launch, runtimeLayer.Build, runtimeLayer.Cache = true, false, false
+	runtimeLayer.Metadata = map[string]interface{}{
+		DepKey:    dependency.Checksum,
+		BuildKey:  false,
+		LaunchKey: true,
+	}
+	copyRuntimeRecords(nodeLayer, runtimeLayer)
+
+	logger.Subprocess("Copying the Node runtime into %s", runtimeLayer.Path)
+	duration, err := clock.Measure(func() error {
+		err := os.MkdirAll(filepath.Join(runtimeLayer.Path, "bin"), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = fs.Copy(filepath.Join(nodeLayer.Path, "bin", "node"), filepath.Join(runtimeLayer.Path, "bin", "node"))
+		if err != nil {
+			return err
+		}
+
+		// The license is kept next to the binary it covers.
+		exists, err := fs.Exists(filepath.Join(nodeLayer.Path, "LICENSE"))
+		if err != nil || !exists {
+			return err
+		}
+
+		return fs.Copy(filepath.Join(nodeLayer.Path, "LICENSE"), filepath.Join(runtimeLayer.Path, "LICENSE"))
+	})
+	if err != nil {
+		return packit.Layer{}, fmt.Errorf("failed to copy the Node runtime: %w", err)
+	}
+
+	logger.Action("Completed in %s", duration.Round(time.Millisecond))
+	logger.Break()
+
+	if !sbomDisabled {
+		logger.GeneratingSBOM(runtimeLayer.Path)
+		var sbomContent sbom.SBOM
+		duration, err = clock.Measure(func() error {
+			sbomContent, err = sbomGenerator.GenerateFromDependency(dependency, runtimeLayer.Path)
+			return err
+		})
+		if err != nil {
+			return packit.Layer{}, err
+		}
+
+		logger.Action("Completed in %s", duration.Round(time.Millisecond))
+		logger.Break()
+
+		logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
+		runtimeLayer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+		if err != nil {
+			return packit.Layer{}, err
+		}
+	}
+
+	return configureRuntime(context, runtimeLayer, logger), nil
+}
+
+// configureRuntime sets up the launch environment and exec.d helpers of the
+// node-runtime layer, which the node layer has when it is a launch layer.
+func configureRuntime(context packit.BuildContext, runtimeLayer packit.Layer, logger scribe.Emitter) packit.Layer {
+	runtimeLayer.LaunchEnv.Default("NODE_HOME", runtimeLayer.Path)
+	runtimeLayer.LaunchEnv.Default("NODE_ENV", "production")
+	runtimeLayer.LaunchEnv.Default("NODE_VERBOSE", "false")
+	runtimeLayer.LaunchEnv.Default("NODE_OPTIONS", "--use-openssl-ca")
+	delete(runtimeLayer.LaunchEnv, "OPTIMIZE_MEMORY.default")
+	if os.Getenv("BP_NODE_OPTIMIZE_MEMORY") == "true" {
+		runtimeLayer.LaunchEnv.Default("OPTIMIZE_MEMORY", "true")
+	}
+	logger.EnvironmentVariables(runtimeLayer)
+
+	runtimeLayer.ExecD = []string{
+		filepath.Join(context.CNBPath, "bin", "optimize-memory"),
+		filepath.Join(context.CNBPath, "bin", "inspector"),
+	}
+
+	return runtimeLayer
+}
+
+// copyRuntimeRecords copies the record keys of the node layer metadata to the
+// node-runtime layer metadata, removing the ones the node layer does not have.
+func copyRuntimeRecords(nodeLayer, runtimeLayer packit.Layer) {
+	for _, key := range runtimeRecordKeys {
+		delete(runtimeLayer.Metadata, key)
+		if value, ok := nodeLayer.Metadata[key]; ok {
+			runtimeLayer.Metadata[key] = value
+		}
+	}
+}
@@ -69,6 +69,8 @@ function main {
     buildpack_type=extension
   fi
 
+  release_keys::check
+
   buildpack::archive "${version}" "${buildpack_type}"
   buildpackage::create "${output}" "${buildpack_type}"
 }
@@ -116,6 +118,12 @@ function tools::install() {
   fi
 }
 
+function release_keys::check() {
+  if [[ ! -f "${ROOT_DIR}/release-keys.asc" ]]; then
+    util::print::error "release-keys.asc is missing: run scripts/update-release-keys.sh and commit the keyring"
+  fi
+}
+
 function buildpack::archive() {
   local version
   version="${1}"
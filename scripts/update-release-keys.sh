@@ -0,0 +1,83 @@
+#!/usr/bin/env bash
+
+set -eu
+set -o pipefail
+
+readonly ROOT_DIR="$(cd "$(dirname "${0}")/.." && pwd)"
+readonly RELEASE_KEYS_URL="https://raw.githubusercontent.com/nodejs/release-keys/main"
+
+# shellcheck source=SCRIPTDIR/.util/print.sh
+source "${ROOT_DIR}/scripts/.util/print.sh"
+
+function main() {
+  local output
+  output="${ROOT_DIR}/release-keys.asc"
+
+  while [[ "${#}" != 0 ]]; do
+    case "${1}" in
+      --output|-o)
+        output="${2}"
+        shift 2
+        ;;
+
+      --help|-h)
+        shift 1
+        usage
+        exit 0
+        ;;
+
+      "")
+        # skip if the argument is empty
+        shift 1
+        ;;
+
+      *)
+        util::print::error "unknown argument \"${1}\""
+    esac
+  done
+
+  keys::export "${output}"
+}
+
+function usage() {
+  cat <<-USAGE
+update-release-keys.sh [OPTIONS]
+
+Exports the public keys of the active members of the Node.js release team,
+as listed in https://github.com/nodejs/release-keys, into an armored keyring.
+The keyring is used to verify the signatures of SHASUMS256.txt files. It is
+committed to the repository, so changes to it are reviewed like any other
+change, and packaging fails without it.
+
+OPTIONS
+  --output  -o <path>  path of the keyring (default: release-keys.asc)
+  --help    -h         prints the command usage
+USAGE
+}
+
+function keys::export() {
+  local output gnupghome
+  output="${1}"
+
+  gnupghome="$(mktemp -d)"
+
+  util::print::title "Fetching Node.js release keys..."
+
+  local fingerprints
+  fingerprints="$(curl --fail --silent --show-error --location "${RELEASE_KEYS_URL}/keys.list")"
+
+  for fingerprint in ${fingerprints}; do
+    util::print::info "Importing ${fingerprint}"
+    curl --fail --silent --show-error --location "${RELEASE_KEYS_URL}/keys/${fingerprint}.asc" \
+      | gpg --homedir "${gnupghome}" --batch --quiet --import
+  done
+
+  # shellcheck disable=SC2086
+  gpg --homedir "${gnupghome}" --batch --armor --export ${fingerprints} > "${output}"
+
+  rm -rf "${gnupghome}"
+
+  util::print::success "Wrote Node.js release keys to ${output}"
+}
+
+main "${@:-}"
@@ -0,0 +1,140 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	SecurityPolicyIgnore = "ignore"
+	SecurityPolicyWarn   = "warn"
+	SecurityPolicyFail   = "fail"
+)
+
+// securityPolicy returns the BP_NODE_SECURITY_POLICY policy, which defaults
+// to warning.
+func securityPolicy() (string, error) {
+	switch policy := os.Getenv("BP_NODE_SECURITY_POLICY"); policy {
+	case "":
+		return SecurityPolicyWarn, nil
+	case SecurityPolicyIgnore, SecurityPolicyWarn, SecurityPolicyFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid value for BP_NODE_SECURITY_POLICY: %q, expected %q, %q or %q", policy, SecurityPolicyIgnore, SecurityPolicyWarn, SecurityPolicyFail)
+	}
+}
+
+// securityReleases returns the given dependencies whose entry in the
+// buildpack.toml at the given path sets the security field. The dependency
+// retrieval tool sets the field on the versions that the nodejs.org release
+// index marks as security releases, and postal.Dependency cannot carry it, so
+// the entries are matched on their identity.
+func securityReleases(path string, dependencies []postal.Dependency) ([]postal.Dependency, error) {
+	var buildpack struct {
+		Metadata struct {
+			Dependencies []struct {
+				postal.Dependency
+				Security bool `toml:"security"`
+			} `toml:"dependencies"`
+		} `toml:"metadata"`
+	}
+
+	_, err := toml.DecodeFile(path, &buildpack)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security releases: %w", err)
+	}
+
+	flagged := map[dependencyKey]bool{}
+	for _, dependency := range buildpack.Metadata.Dependencies {
+		if dependency.Security {
+			flagged[keyOf(dependency.Dependency)] = true
+		}
+	}
+
+	var releases []postal.Dependency
+	for _, dependency := range dependencies {
+		if flagged[keyOf(dependency)] {
+			releases = append(releases, dependency)
+		}
+	}
+
+	return releases, nil
+}
+
+// dependencyKey identifies a dependency entry of the buildpack.toml.
+type dependencyKey struct {
+	ID, Version, URI, Checksum, OS, Arch string
+}
+
+func keyOf(dependency postal.Dependency) dependencyKey {
+	return dependencyKey{
+		ID:       dependency.ID,
+		Version:  dependency.Version,
+		URI:      dependency.URI,
+		Checksum: dependency.Checksum,
+		OS:       dependency.OS,
+		Arch:     dependency.Arch,
+	}
+}
+
+// newestSecurityRelease returns the newest of the given security releases
+// that is on the same major line as the selected dependency and newer than
+// it, or nil when there is none.
+func newestSecurityRelease(dependency postal.Dependency, releases []postal.Dependency) (*semver.Version, error) {
+	selected, err := semver.NewVersion(dependency.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version of dependency %q: %w", dependency.Version, err)
+	}
+
+	var newest *semver.Version
+	for _, release := range releases {
+		version, err := semver.NewVersion(release.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse version of dependency %q: %w", release.Version, err)
+		}
+
+		if version.Major() != selected.Major() || !version.GreaterThan(selected) {
+			continue
+		}
+
+		if newest == nil || version.GreaterThan(newest) {
+			newest = version
+		}
+	}
+
+	return newest, nil
+}
+
+// checkSecurityReleases warns, or fails under the fail policy, when the
+// selected dependency is older than the newest security release of its major
+// line that the buildpack carries, which happens when an application pins an
+// exact patch version.
+func checkSecurityReleases(dependency postal.Dependency, releases []postal.Dependency, policy, source string, logger scribe.Emitter) error {
+	if policy == SecurityPolicyIgnore || len(releases) == 0 {
+		return nil
+	}
+
+	newest, err := newestSecurityRelease(dependency, releases)
+	if err != nil {
+		return err
+	}
+
+	if newest == nil {
+		return nil
+	}
+
+	if policy == SecurityPolicyFail {
+		return fmt.Errorf("%s %s is older than %s, the newest security release of the %d.x line, and BP_NODE_SECURITY_POLICY is set to %q: update the version requested by %s", dependency.Name, dependency.Version, newest, newest.Major(), SecurityPolicyFail, source)
+	}
+
+	logger.Process("WARNING: %s %s is older than %s, the newest security release of the %d.x line.", dependency.Name, dependency.Version, newest, newest.Major())
+	logger.Subprocess("Update the version requested by %s to install the security fixes.", source)
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package nodeengine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+)
+
+// ReleaseKeysFile is the name of the file at the root of the buildpack that
+// holds the armored public keys of the Node.js release team. It is generated
+// by scripts/update-release-keys.sh.
+const ReleaseKeysFile = "release-keys.asc"
+
+// ReleaseKeys holds the public keys of the Node.js release team, which sign
+// the SHASUMS256.txt files of the Node releases.
+type ReleaseKeys struct {
+	keyring openpgp.EntityList
+}
+
+// NewReleaseKeys reads the armored keyring at the given path.
+func NewReleaseKeys(path string) (ReleaseKeys, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ReleaseKeys{}, fmt.Errorf("failed to read Node release keys: %w", err)
+	}
+	defer file.Close()
+
+	keyring, err := openpgp.ReadArmoredKeyRing(file)
+	if err != nil {
+		return ReleaseKeys{}, fmt.Errorf("failed to read Node release keys from %s: %w", path, err)
+	}
+
+	return ReleaseKeys{keyring: keyring}, nil
+}
+
+// VerifiedSHASUMS fetches the SHASUMS256.txt file at the given URI and
+// verifies it against its signature, which is either published next to it as
+// a binary detached signature with a .sig extension, or as an armored
+// signature with a .asc extension. Like on nodejs.org, the .asc file can be a
+// clearsigned copy of the SHASUMS256.txt file, in which case its signed
+// content is returned. It fails when no signature is published or when the
+// signature was not made by one of the release keys.
+func (k ReleaseKeys) VerifiedSHASUMS(uri string) ([]byte, error) {
+	content, err := fetch(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := fetch(uri + ".sig")
+	if err == nil {
+		if _, err := openpgp.CheckDetachedSignature(k.keyring, bytes.NewReader(content), bytes.NewReader(signature), nil); err != nil {
+			return nil, fmt.Errorf("invalid signature %s.sig: %w", uri, err)
+		}
+
+		return content, nil
+	}
+
+	if !isNotFound(err) {
+		return nil, err
+	}
+
+	signature, err = fetch(uri + ".asc")
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("no signature found for %[1]s: expected %[1]s.sig or %[1]s.asc", uri)
+		}
+		return nil, err
+	}
+
+	if block, _ := clearsign.Decode(signature); block != nil {
+		if _, err := block.VerifySignature(k.keyring, nil); err != nil {
+			return nil, fmt.Errorf("invalid signature %s.asc: %w", uri, err)
+		}
+
+		return block.Plaintext, nil
+	}
+
+	if _, err := openpgp.CheckArmoredDetachedSignature(k.keyring, bytes.NewReader(content), bytes.NewReader(signature), nil); err != nil {
+		return nil, fmt.Errorf("invalid signature %s.asc: %w", uri, err)
+	}
+
+	return content, nil
+}
+
+func isNotFound(err error) bool {
+	var statusErr fetchStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == 404
+}
@@ -0,0 +1,191 @@
+package nodeengine_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+// newSigner returns a new signing key.
+func newSigner(t *testing.T, name string) *openpgp.Entity {
+	signer, err := openpgp.NewEntity(name, "", fmt.Sprintf("%s@example.com", strings.ToLower(strings.ReplaceAll(name, " ", "."))), &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	return signer
+}
+
+// writeReleaseKeys writes a keyring holding the public key of a new signer to
+// the given path, and returns the signer.
+func writeReleaseKeys(t *testing.T, path string) *openpgp.Entity {
+	Expect := NewWithT(t).Expect
+
+	signer := newSigner(t, "Node.js Release")
+
+	file, err := os.Create(path)
+	Expect(err).NotTo(HaveOccurred())
+	armored, err := armor.Encode(file, openpgp.PublicKeyType, nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(signer.Serialize(armored)).To(Succeed())
+	Expect(armored.Close()).To(Succeed())
+	Expect(file.Close()).To(Succeed())
+
+	return signer
+}
+
+// detachSign returns a binary detached signature of the given content.
+func detachSign(t *testing.T, signer *openpgp.Entity, content string) []byte {
+	signature := bytes.NewBuffer(nil)
+	NewWithT(t).Expect(openpgp.DetachSign(signature, signer, strings.NewReader(content), nil)).To(Succeed())
+	return signature.Bytes()
+}
+
+func testSignature(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		keys       nodeengine.ReleaseKeys
+		signer     *openpgp.Entity
+		server     *httptest.Server
+		shasums    string
+		content    string
+		signatures map[string][]byte
+	)
+
+	it.Before(func() {
+		keysPath := filepath.Join(t.TempDir(), "release-keys.asc")
+		signer = writeReleaseKeys(t, keysPath)
+
+		var err error
+		keys, err = nodeengine.NewReleaseKeys(keysPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		shasums = fmt.Sprintf("%s  node-v22.11.0-linux-x64.tar.xz\n", strings.Repeat("a", 64))
+		content = shasums
+		signatures = map[string][]byte{"/SHASUMS256.txt.sig": detachSign(t, signer, shasums)}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/SHASUMS256.txt" {
+				fmt.Fprint(w, content)
+				return
+			}
+
+			signature, ok := signatures[req.URL.Path]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+			_, _ = w.Write(signature)
+		}))
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	it("returns the content when its .sig signature is valid", func() {
+		verified, err := keys.VerifiedSHASUMS(server.URL + "/SHASUMS256.txt")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(verified)).To(Equal(shasums))
+	})
+
+	context("when the file is clearsigned in a .asc file", func() {
+		it.Before(func() {
+			clearsigned := bytes.NewBuffer(nil)
+			plaintext, err := clearsign.Encode(clearsigned, signer.PrivateKey, nil)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = plaintext.Write([]byte(shasums))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plaintext.Close()).To(Succeed())
+
+			// The served copy is not the one that is signed, so that only the
+			// signed content can be returned.
+			content = "unsigned"
+			signatures = map[string][]byte{"/SHASUMS256.txt.asc": clearsigned.Bytes()}
+		})
+
+		it("returns the signed content", func() {
+			verified, err := keys.VerifiedSHASUMS(server.URL + "/SHASUMS256.txt")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(verified)).To(Equal(shasums))
+		})
+	})
+
+	context("when the file has an armored detached .asc signature", func() {
+		it.Before(func() {
+			signature := bytes.NewBuffer(nil)
+			Expect(openpgp.ArmoredDetachSign(signature, signer, strings.NewReader(shasums), nil)).To(Succeed())
+			signatures = map[string][]byte{"/SHASUMS256.txt.asc": signature.Bytes()}
+		})
+
+		it("returns the content", func() {
+			verified, err := keys.VerifiedSHASUMS(server.URL + "/SHASUMS256.txt")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(verified)).To(Equal(shasums))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the file is signed by an unknown key", func() {
+			it.Before(func() {
+				signatures = map[string][]byte{"/SHASUMS256.txt.sig": detachSign(t, newSigner(t, "Someone Else"), shasums)}
+			})
+
+			it("returns an error", func() {
+				_, err := keys.VerifiedSHASUMS(server.URL + "/SHASUMS256.txt")
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("invalid signature %s/SHASUMS256.txt.sig", server.URL))))
+			})
+		})
+
+		context("when the file was altered after signing", func() {
+			it.Before(func() {
+				content = strings.Replace(shasums, strings.Repeat("a", 64), strings.Repeat("f", 64), 1)
+			})
+
+			it("returns an error", func() {
+				_, err := keys.VerifiedSHASUMS(server.URL + "/SHASUMS256.txt")
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("invalid signature %s/SHASUMS256.txt.sig", server.URL))))
+			})
+		})
+
+		context("when the file is not signed", func() {
+			it.Before(func() {
+				signatures = map[string][]byte{}
+			})
+
+			it("returns an error", func() {
+				_, err := keys.VerifiedSHASUMS(server.URL + "/SHASUMS256.txt")
+				Expect(err).To(MatchError(fmt.Sprintf("no signature found for %[1]s/SHASUMS256.txt: expected %[1]s/SHASUMS256.txt.sig or %[1]s/SHASUMS256.txt.asc", server.URL)))
+			})
+		})
+
+		context("when the keyring cannot be read", func() {
+			it("returns an error", func() {
+				_, err := nodeengine.NewReleaseKeys(filepath.Join(t.TempDir(), "missing.asc"))
+				Expect(err).To(MatchError(ContainSubstring("failed to read Node release keys")))
+			})
+		})
+
+		context("when the keyring is not armored", func() {
+			it("returns an error", func() {
+				path := filepath.Join(t.TempDir(), "release-keys.asc")
+				Expect(os.WriteFile(path, []byte("not a keyring"), 0600)).To(Succeed())
+
+				_, err := nodeengine.NewReleaseKeys(path)
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to read Node release keys from %s", path))))
+			})
+		})
+	})
+}
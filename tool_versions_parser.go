@@ -0,0 +1,132 @@
+package nodeengine
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+type ToolVersionsParser struct {
+	aliasResolver AliasResolver
+}
+
+func NewToolVersionsParser(aliasResolver AliasResolver) ToolVersionsParser {
+	return ToolVersionsParser{
+		aliasResolver: aliasResolver,
+	}
+}
+
+func (p ToolVersionsParser) ParseVersion(path string) (VersionResult, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return VersionResult{}, nil
+		}
+		return VersionResult{}, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for i := 1; scanner.Scan(); i++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !isNodeTool(fields[0]) {
+			continue
+		}
+
+		version, err := formatToolVersions(fields[1:], ToolVersionsSource, p.aliasResolver)
+		if err != nil {
+			return VersionResult{}, err
+		}
+
+		return VersionResult{
+			Raw:        strings.Join(fields[1:], " "),
+			Constraint: version,
+			Path:       path,
+			Line:       i,
+		}, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return VersionResult{}, err
+	}
+
+	return VersionResult{}, nil
+}
+
+// isNodeTool reports whether the given asdf or mise tool name refers to
+// Node.js.
+func isNodeTool(name string) bool {
+	return name == "nodejs" || name == Node
+}
+
+// formatToolVersions converts the list of versions given for a tool in an
+// asdf or mise configuration file into a single version constraint. Each
+// version after the first is a fallback, so the versions are joined as
+// alternatives in the order they were given, which firstAvailableToolVersion
+// tries in turn during the build.
+func formatToolVersions(versions []string, source string, aliasResolver AliasResolver) (string, error) {
+	var constraints []string
+	for _, version := range versions {
+		version = strings.TrimSpace(strings.ToLower(version))
+
+		// "system" delegates to a Node.js installation outside of the version
+		// manager, which puts no constraint on the version that is installed.
+		if version == "system" {
+			continue
+		}
+
+		constraint, ok, err := aliasResolver.ResolveVersion(version)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return "", fmt.Errorf("invalid version constraint specified in %s: %q", source, version)
+		}
+
+		constraints = append(constraints, constraint)
+	}
+
+	return strings.Join(constraints, " || "), nil
+}
+
+// firstAvailableToolVersion narrows the version of a plan entry read from an
+// asdf or mise configuration file to the first of its alternatives that one of
+// the dependencies satisfies, since the versions listed for a tool are
+// fallbacks that are tried in order rather than a range where the highest
+// version wins. The entry is returned unchanged when it comes from another
+// source, or when none of its alternatives is available.
+func firstAvailableToolVersion(entry packit.BuildpackPlanEntry, dependencies []postal.Dependency) packit.BuildpackPlanEntry {
+	switch source, _ := entry.Metadata["version-source"].(string); source {
+	case ToolVersionsSource, MiseSource, DotMiseSource:
+	default:
+		return entry
+	}
+
+	version, _ := entry.Metadata["version"].(string)
+	alternatives := strings.Split(version, " || ")
+	if len(alternatives) < 2 {
+		return entry
+	}
+
+	for _, alternative := range alternatives {
+		candidate := entry
+		candidate.Metadata = map[string]interface{}{}
+		for key, value := range entry.Metadata {
+			candidate.Metadata[key] = value
+		}
+		candidate.Metadata["version"] = alternative
+
+		if checkSatisfiable(candidate, dependencies) == nil {
+			return candidate
+		}
+	}
+
+	return entry
+}
@@ -0,0 +1,112 @@
+package nodeengine_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testToolVersionsParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser nodeengine.ToolVersionsParser
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), ".tool-versions")
+
+		buildpackTOMLPath := filepath.Join(t.TempDir(), "buildpack.toml")
+		Expect(os.WriteFile(buildpackTOMLPath, []byte(`
+[metadata.lts-codenames]
+  gallium = "16"
+  hydrogen = "18"
+  iron = "20"
+  jod = "22"
+`), 0600)).To(Succeed())
+
+		parser = nodeengine.NewToolVersionsParser(nodeengine.NewAliasResolver(nodeengine.NewLTSCodenames(buildpackTOMLPath)))
+	})
+
+	it("returns a version constraint", func() {
+		testCases := map[string]string{
+			"nodejs 20.11.1":                       "20.11.1",
+			"node 20.11.1":                         "20.11.1",
+			"nodejs v20":                           "20",
+			"nodejs 20.11.1 18.19.0":               "20.11.1 || 18.19.0",
+			"nodejs lts":                           "22.*",
+			"nodejs lts-hydrogen":                  "18.*",
+			"nodejs lts-jod":                       "22.*",
+			"nodejs lts/gallium":                   "16.*",
+			"nodejs latest":                        "*",
+			"nodejs system":                        "",
+			"nodejs system 20.11.1":                "20.11.1",
+			"ruby 3.3.0\nnodejs 20.11.1\n":         "20.11.1",
+			"# pinned\nnodejs 20.11.1 # current\n": "20.11.1",
+			"\n\nnodejs   20.11.1   \n":            "20.11.1",
+			"ruby 3.3.0\n":                         "",
+			"nodejs 23.0.0-rc.1":                   "23.0.0-rc.1",
+		}
+
+		for input, output := range testCases {
+			err := os.WriteFile(path, []byte(input), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Constraint).To(Equal(output), fmt.Sprintf("input of %q failed to produce output of %q", input, output))
+		}
+	})
+
+	it("reports where the version was found", func() {
+		Expect(os.WriteFile(path, []byte("python 3.12\nnodejs 20.11.1 lts-hydrogen # fallback\n"), 0644)).To(Succeed())
+
+		result, err := parser.ParseVersion(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(nodeengine.VersionResult{
+			Raw:        "20.11.1 lts-hydrogen",
+			Constraint: "20.11.1 || 18.*",
+			Path:       path,
+			Line:       2,
+		}))
+	})
+
+	context("when the .tool-versions file does not exist", func() {
+		it("returns an empty result", func() {
+			result, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(nodeengine.VersionResult{}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the .tool-versions contains a malformed version", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte("nodejs 20.11.1 ref:v1.0.2"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError("invalid version constraint specified in .tool-versions: \"ref:v1.0.2\""))
+			})
+		})
+
+		context("when the .tool-versions contains an unknown LTS codename", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte("nodejs lts-unknown"), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseVersion(path)
+				Expect(err).To(MatchError("invalid version constraint specified in .tool-versions: \"lts-unknown\""))
+			})
+		})
+	})
+}
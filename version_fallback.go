@@ -0,0 +1,106 @@
+package nodeengine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+const (
+	FallbackFail      = "fail"
+	FallbackSameMinor = "same-minor"
+	FallbackSameMajor = "same-major"
+)
+
+// VersionFallback records that the requested version was not available and
+// was substituted according to the BP_NODE_VERSION_FALLBACK policy. It is
+// persisted in the layer metadata under FallbackKey.
+type VersionFallback struct {
+	Policy    string `toml:"policy"`
+	Source    string `toml:"source"`
+	Requested string `toml:"requested"`
+	Fallback  string `toml:"fallback"`
+	Resolved  string `toml:"resolved"`
+}
+
+// releasePattern matches a plain X, X.Y or X.Y.Z version, optionally preceded
+// by a ~ or ^ range operator. Other constraints, like "<20.11" or ">=18 <21",
+// bound the version in ways that a fallback would not respect.
+var releasePattern = regexp.MustCompile(`^[~^]?v?(\d+)(?:\.(\d+)(?:\.\d+)?)?$`)
+
+// fallbackPolicy returns the BP_NODE_VERSION_FALLBACK policy, which defaults
+// to failing the build.
+func fallbackPolicy(setting string) (string, error) {
+	switch setting {
+	case "", FallbackFail:
+		return FallbackFail, nil
+	case FallbackSameMinor, FallbackSameMajor:
+		return setting, nil
+	default:
+		return "", fmt.Errorf("invalid value for BP_NODE_VERSION_FALLBACK: %q, expected %q, %q or %q", setting, FallbackFail, FallbackSameMinor, FallbackSameMajor)
+	}
+}
+
+// fallbackConstraint loosens the given version constraint according to the
+// policy: "same-minor" accepts any patch of its minor release and
+// "same-major" any release of its major line. It reports false when the
+// constraint is not a plain version, and so cannot be loosened.
+func fallbackConstraint(version, policy string) (string, bool) {
+	match := releasePattern.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return "", false
+	}
+
+	switch policy {
+	case FallbackSameMinor:
+		if match[2] == "" {
+			return "", false
+		}
+		return fmt.Sprintf("%s.%s.*", match[1], match[2]), true
+	case FallbackSameMajor:
+		return fmt.Sprintf("%s.*", match[1]), true
+	}
+
+	return "", false
+}
+
+// applyFallback returns a copy of the given plan entry whose version has been
+// loosened according to the policy, along with a record of the substitution,
+// when the loosened version can be satisfied. Otherwise, it returns the given
+// error, which explains why the original version could not be satisfied.
+func applyFallback(entry packit.BuildpackPlanEntry, dependencies []postal.Dependency, policy string, unsatisfiedErr error) (packit.BuildpackPlanEntry, VersionFallback, error) {
+	if policy == FallbackFail {
+		return packit.BuildpackPlanEntry{}, VersionFallback{}, unsatisfiedErr
+	}
+
+	version, _ := entry.Metadata["version"].(string)
+	constraint, ok := fallbackConstraint(version, policy)
+	if !ok {
+		return packit.BuildpackPlanEntry{}, VersionFallback{}, unsatisfiedErr
+	}
+
+	metadata := map[string]interface{}{}
+	for key, value := range entry.Metadata {
+		metadata[key] = value
+	}
+	metadata["version"] = constraint
+
+	fallbackEntry := packit.BuildpackPlanEntry{
+		Name:     entry.Name,
+		Metadata: metadata,
+	}
+
+	if err := checkSatisfiable(fallbackEntry, dependencies); err != nil {
+		return packit.BuildpackPlanEntry{}, VersionFallback{}, unsatisfiedErr
+	}
+
+	return fallbackEntry, VersionFallback{
+		Policy:    policy,
+		Source:    planEntryLabel(entry),
+		Requested: version,
+		Fallback:  constraint,
+	}, nil
+}
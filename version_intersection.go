@@ -0,0 +1,163 @@
+package nodeengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+const (
+	ResolutionPriority  = "priority"
+	ResolutionIntersect = "intersect"
+)
+
+// IntersectionError is returned when no dependency satisfies every version
+// requirement of the plan. It lists each requirement along with the
+// dependencies that satisfy it on its own.
+type IntersectionError struct {
+	Requirements []IntersectionRequirement
+}
+
+type IntersectionRequirement struct {
+	Source  string
+	Version string
+	Matches []string
+}
+
+func (e IntersectionError) Error() string {
+	var width int
+	for _, requirement := range e.Requirements {
+		if len(requirement.Source) > width {
+			width = len(requirement.Source)
+		}
+	}
+
+	lines := []string{"no version of node satisfies every version requirement:"}
+	for _, requirement := range e.Requirements {
+		matches := "no matching versions"
+		if len(requirement.Matches) > 0 {
+			matches = "matches " + strings.Join(requirement.Matches, ", ")
+		}
+
+		lines = append(lines, fmt.Sprintf("  %-"+strconv.Itoa(width)+"s -> %q (%s)", requirement.Source, requirement.Version, matches))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Entry returns a plan entry whose version constraint is satisfied by the
+// versions that satisfy every requirement, so that they can be looked up
+// beyond the dependencies of the buildpack.toml. A semver constraint cannot
+// group alternatives, so the alternatives of the requirements are combined
+// into every possible conjunction.
+func (e IntersectionError) Entry() packit.BuildpackPlanEntry {
+	conjunctions := []string{""}
+	var sources []string
+	for _, requirement := range e.Requirements {
+		var combined []string
+		for _, conjunction := range conjunctions {
+			for _, alternative := range strings.Split(requirement.Version, "||") {
+				alternative = strings.TrimSpace(alternative)
+				if conjunction != "" {
+					alternative = fmt.Sprintf("%s, %s", conjunction, alternative)
+				}
+
+				combined = append(combined, alternative)
+			}
+		}
+
+		conjunctions = combined
+		sources = append(sources, requirement.Source)
+	}
+
+	return packit.BuildpackPlanEntry{
+		Name: Node,
+		Metadata: map[string]interface{}{
+			"version":        strings.Join(conjunctions, " || "),
+			"version-source": strings.Join(sources, ", "),
+		},
+	}
+}
+
+// intersectVersions returns a plan entry requesting the newest dependency
+// that satisfies the version of every given entry. The returned entry names
+// all of the sources that were intersected, and is empty when none of the
+// entries constrain the version. Entries without a version, and
+// devEngines.runtime entries whose onFail setting tolerates a mismatch, do
+// not constrain the intersection.
+func intersectVersions(entries []packit.BuildpackPlanEntry, dependencies []postal.Dependency) (packit.BuildpackPlanEntry, error) {
+	type requirement struct {
+		source     string
+		version    string
+		constraint *semver.Constraints
+	}
+
+	var requirements []requirement
+	for _, entry := range entries {
+		version, _ := entry.Metadata["version"].(string)
+		if version == "" || version == "default" {
+			continue
+		}
+
+		source := planEntryLabel(entry)
+		if onFail, _ := entry.Metadata["on-fail"].(string); entry.Metadata["version-source"] == DevEnginesSource && (onFail == OnFailIgnore || onFail == OnFailWarn) {
+			continue
+		}
+
+		constraint, err := semver.NewConstraint(version)
+		if err != nil {
+			return packit.BuildpackPlanEntry{}, fmt.Errorf("invalid version constraint %q from %s: %w", version, source, err)
+		}
+
+		requirements = append(requirements, requirement{source, version, constraint})
+	}
+
+	if len(requirements) == 0 {
+		return packit.BuildpackPlanEntry{}, nil
+	}
+
+	var sources []string
+	for _, r := range requirements {
+		sources = append(sources, r.source)
+	}
+
+Dependencies:
+	for _, dependency := range dependencies {
+		version := semver.MustParse(dependency.Version)
+		for _, r := range requirements {
+			if !r.constraint.Check(version) {
+				continue Dependencies
+			}
+		}
+
+		return packit.BuildpackPlanEntry{
+			Name: Node,
+			Metadata: map[string]interface{}{
+				"version":        dependency.Version,
+				"version-source": strings.Join(sources, ", "),
+			},
+		}, nil
+	}
+
+	var intersectionErr IntersectionError
+	for _, r := range requirements {
+		var matches []string
+		for _, dependency := range dependencies {
+			if r.constraint.Check(semver.MustParse(dependency.Version)) {
+				matches = append(matches, dependency.Version)
+			}
+		}
+
+		intersectionErr.Requirements = append(intersectionErr.Requirements, IntersectionRequirement{
+			Source:  r.source,
+			Version: r.version,
+			Matches: matches,
+		})
+	}
+
+	return packit.BuildpackPlanEntry{}, intersectionErr
+}
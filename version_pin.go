@@ -0,0 +1,103 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// VersionPin keeps rebuilds on the Node version resolved by a previous build,
+// which is recorded in the layer metadata under PinnedVersionKey, instead of
+// letting floating version requirements pick up newer releases.
+type VersionPin struct {
+	Enabled bool
+	Upgrade bool
+}
+
+// versionPinFromEnvironment reads whether pinning is enabled from
+// BP_NODE_PIN_RESOLVED and whether the pin should be released for this build
+// from BP_NODE_UPGRADE.
+func versionPinFromEnvironment() (VersionPin, error) {
+	var pin VersionPin
+	if enabledStr, ok := os.LookupEnv("BP_NODE_PIN_RESOLVED"); ok {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return VersionPin{}, fmt.Errorf("failed to parse BP_NODE_PIN_RESOLVED value %s: %w", enabledStr, err)
+		}
+		pin.Enabled = enabled
+	}
+
+	if upgradeStr, ok := os.LookupEnv("BP_NODE_UPGRADE"); ok {
+		upgrade, err := strconv.ParseBool(upgradeStr)
+		if err != nil {
+			return VersionPin{}, fmt.Errorf("failed to parse BP_NODE_UPGRADE value %s: %w", upgradeStr, err)
+		}
+		pin.Upgrade = upgrade
+	}
+
+	return pin, nil
+}
+
+// Apply returns a copy of the given plan entry requesting the version pinned
+// in the layer metadata, when there is one and it can still be selected: it
+// satisfies the version requested by the entry, it is shipped with the
+// buildpack and it is not past its end of life. Otherwise, it returns the
+// given entry.
+func (p VersionPin) Apply(entry packit.BuildpackPlanEntry, metadata map[string]interface{}, dependencies []postal.Dependency, now time.Time, logger scribe.Emitter) packit.BuildpackPlanEntry {
+	pinned, _ := metadata[PinnedVersionKey].(string)
+	if !p.Enabled || pinned == "" {
+		return entry
+	}
+
+	if p.Upgrade {
+		logger.Subprocess("Releasing pinned version %s, as requested by BP_NODE_UPGRADE", pinned)
+		return entry
+	}
+
+	version, _ := entry.Metadata["version"].(string)
+	if version != "" && version != "default" {
+		constraint, err := semver.NewConstraint(version)
+		pinnedVersion, pinnedErr := semver.NewVersion(pinned)
+		if err != nil || pinnedErr != nil || !constraint.Check(pinnedVersion) {
+			logger.Subprocess("Releasing pinned version %s, which does not satisfy %q", pinned, version)
+			return entry
+		}
+	}
+
+	var shipped bool
+	for _, dependency := range dependencies {
+		if dependency.Version != pinned {
+			continue
+		}
+
+		shipped = true
+		if !dependency.DeprecationDate.IsZero() && !now.Before(dependency.DeprecationDate) {
+			logger.Subprocess("Releasing pinned version %s, which reached its end of life on %s", pinned, dependency.DeprecationDate.Format("2006-01-02"))
+			return entry
+		}
+	}
+
+	if !shipped {
+		logger.Subprocess("Releasing pinned version %s, which is no longer shipped with the buildpack", pinned)
+		return entry
+	}
+
+	logger.Subprocess("Keeping pinned version %s, set BP_NODE_UPGRADE=true to select a newer version", pinned)
+
+	pinnedMetadata := map[string]interface{}{}
+	for key, value := range entry.Metadata {
+		pinnedMetadata[key] = value
+	}
+	pinnedMetadata["version"] = pinned
+
+	return packit.BuildpackPlanEntry{
+		Name:     entry.Name,
+		Metadata: pinnedMetadata,
+	}
+}
@@ -0,0 +1,286 @@
+package nodeengine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// VersionResult is a Node version requirement found by a version source.
+type VersionResult struct {
+	// Raw is the version as it was written in the source.
+	Raw string
+	// Constraint is the version constraint that Raw resolves to.
+	Constraint string
+	// Path is the file the version was read from, and is empty for sources
+	// that are not file-based.
+	Path string
+	// Line is the line of Path that the version was read from, and is 0 when
+	// the source format does not track lines.
+	Line int
+
+	Npm    string
+	Yarn   string
+	OnFail string
+}
+
+// VersionSource is a named source of the Node version requirement of an
+// application. Find reports whether the source specifies a requirement for
+// the application at projectPath, a directory inside of workingDir. A source
+// that does not specify a requirement can still return the npm and Yarn
+// versions pinned for the application.
+//
+//go:generate faux --interface VersionSource --output fakes/version_source.go
+type VersionSource interface {
+	Name() string
+	Find(workingDir, projectPath string) (result VersionResult, found bool, err error)
+}
+
+// VersionSourceRegistry holds the version sources that Detect consults, in
+// the order their requirements are added to the build plan.
+type VersionSourceRegistry struct {
+	sources []VersionSource
+}
+
+func NewVersionSourceRegistry(sources ...VersionSource) VersionSourceRegistry {
+	return VersionSourceRegistry{
+		sources: sources,
+	}
+}
+
+// Enabled returns the sources enabled by the given BP_NODE_VERSION_SOURCES
+// setting, a comma-separated list of source names. When the setting is
+// empty, every source is enabled. When every name in the list is prefixed
+// with "-", every source except the listed ones is enabled. Otherwise, only
+// the listed sources are enabled.
+func (r VersionSourceRegistry) Enabled(setting string) ([]VersionSource, error) {
+	if strings.TrimSpace(setting) == "" {
+		return r.sources, nil
+	}
+
+	known := map[string]bool{}
+	for _, source := range r.sources {
+		known[source.Name()] = true
+	}
+
+	var (
+		listed             = map[string]bool{}
+		included, excluded int
+	)
+
+	for _, name := range strings.Split(setting, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if trimmed, ok := strings.CutPrefix(name, "-"); ok {
+			name = trimmed
+			excluded++
+		} else {
+			included++
+		}
+
+		if !known[name] {
+			return nil, fmt.Errorf("unknown version source %q in BP_NODE_VERSION_SOURCES, expected one of: %s", name, strings.Join(r.names(), ", "))
+		}
+
+		listed[name] = true
+	}
+
+	if included > 0 && excluded > 0 {
+		return nil, fmt.Errorf("BP_NODE_VERSION_SOURCES must either list the sources to enable or the sources to disable, not both: %q", setting)
+	}
+
+	var sources []VersionSource
+	for _, source := range r.sources {
+		if listed[source.Name()] == (included > 0) {
+			sources = append(sources, source)
+		}
+	}
+
+	return sources, nil
+}
+
+func (r VersionSourceRegistry) names() []string {
+	var names []string
+	for _, source := range r.sources {
+		names = append(names, source.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// FileVersionSource reads the version requirement from a file at the root of
+// the application using a VersionParser.
+type FileVersionSource struct {
+	name     string
+	filename string
+	parser   VersionParser
+}
+
+func NewFileVersionSource(name, filename string, parser VersionParser) FileVersionSource {
+	return FileVersionSource{
+		name:     name,
+		filename: filename,
+		parser:   parser,
+	}
+}
+
+func (s FileVersionSource) Name() string {
+	return s.name
+}
+
+func (s FileVersionSource) Find(workingDir, projectPath string) (VersionResult, bool, error) {
+	path := filepath.Join(projectPath, s.filename)
+	err := checkVersionFile(s.name, workingDir, path)
+	if err != nil {
+		return VersionResult{}, false, err
+	}
+
+	result, err := s.parser.ParseVersion(path)
+	if err != nil {
+		return VersionResult{}, false, err
+	}
+
+	return result, result.Constraint != "", nil
+}
+
+// EnvironmentVersionSource reads the version requirement from an environment
+// variable. The value is validated with the same rules as the version files,
+// so that a typo fails detection instead of the dependency resolution.
+type EnvironmentVersionSource struct {
+	variable string
+	resolver VersionResolver
+}
+
+func NewEnvironmentVersionSource(variable string, resolver VersionResolver) EnvironmentVersionSource {
+	return EnvironmentVersionSource{
+		variable: variable,
+		resolver: resolver,
+	}
+}
+
+func (s EnvironmentVersionSource) Name() string {
+	return s.variable
+}
+
+func (s EnvironmentVersionSource) Find(workingDir, projectPath string) (VersionResult, bool, error) {
+	version := os.Getenv(s.variable)
+	if version == "" {
+		return VersionResult{}, false, nil
+	}
+
+	constraint, ok, err := s.resolver.ResolveVersion(version)
+	if err != nil {
+		return VersionResult{}, false, err
+	}
+
+	if !ok {
+		return VersionResult{}, false, fmt.Errorf("invalid version constraint specified in %s: %q", s.variable, version)
+	}
+
+	return VersionResult{Raw: version, Constraint: constraint}, true, nil
+}
+
+// VoltaVersionSource reads the Volta toolchain pinned in package.json.
+type VoltaVersionSource struct {
+	parser ToolchainParser
+}
+
+func NewVoltaVersionSource(parser ToolchainParser) VoltaVersionSource {
+	return VoltaVersionSource{
+		parser: parser,
+	}
+}
+
+func (s VoltaVersionSource) Name() string {
+	return VoltaSource
+}
+
+func (s VoltaVersionSource) Find(workingDir, projectPath string) (VersionResult, bool, error) {
+	path := filepath.Join(projectPath, PackageJSONSource)
+	err := checkVersionFile(VoltaSource, workingDir, path)
+	if err != nil {
+		return VersionResult{}, false, err
+	}
+
+	toolchain, err := s.parser.ParseToolchain(workingDir, path)
+	if err != nil {
+		return VersionResult{}, false, err
+	}
+
+	if toolchain == (Toolchain{}) {
+		return VersionResult{}, false, nil
+	}
+
+	// A toolchain that only pins package managers does not request a Node
+	// version, so its pins are returned without a requirement to be attached
+	// to the requirement of another source.
+	return VersionResult{
+		Raw:        toolchain.Node,
+		Constraint: toolchain.Node,
+		Path:       path,
+		Npm:        toolchain.Npm,
+		Yarn:       toolchain.Yarn,
+	}, toolchain.Node != "", nil
+}
+
+// DevEnginesVersionSource reads the devEngines.runtime field of package.json.
+type DevEnginesVersionSource struct {
+	parser RuntimeParser
+}
+
+func NewDevEnginesVersionSource(parser RuntimeParser) DevEnginesVersionSource {
+	return DevEnginesVersionSource{
+		parser: parser,
+	}
+}
+
+func (s DevEnginesVersionSource) Name() string {
+	return DevEnginesSource
+}
+
+func (s DevEnginesVersionSource) Find(workingDir, projectPath string) (VersionResult, bool, error) {
+	path := filepath.Join(projectPath, PackageJSONSource)
+	err := checkVersionFile(DevEnginesSource, workingDir, path)
+	if err != nil {
+		return VersionResult{}, false, err
+	}
+
+	runtime, err := s.parser.ParseRuntime(path)
+	if err != nil {
+		return VersionResult{}, false, err
+	}
+
+	if runtime.Version == "" {
+		return VersionResult{}, false, nil
+	}
+
+	return VersionResult{
+		Raw:        runtime.Version,
+		Constraint: runtime.Version,
+		Path:       path,
+		OnFail:     runtime.OnFail,
+	}, true, nil
+}
+
+// checkVersionFile fails detection when the version file at the given path,
+// once its symlinks are resolved, is outside of the application directory.
+func checkVersionFile(source, workingDir, path string) error {
+	within, err := resolvesWithin(workingDir, path)
+	if err != nil {
+		return err
+	}
+
+	if !within {
+		return packit.Fail.WithMessage("expected %s version file [%s] to be inside the application directory [%s]", source, path, workingDir)
+	}
+
+	return nil
+}
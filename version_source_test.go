@@ -0,0 +1,247 @@
+package nodeengine_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/paketo-buildpacks/node-engine/v5/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVersionSource(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("VersionSourceRegistry", func() {
+		var (
+			nvmrc, packageJSON, volta *fakes.VersionSource
+			registry                  nodeengine.VersionSourceRegistry
+		)
+
+		it.Before(func() {
+			nvmrc = &fakes.VersionSource{}
+			nvmrc.NameCall.Returns.String = ".nvmrc"
+			packageJSON = &fakes.VersionSource{}
+			packageJSON.NameCall.Returns.String = "package.json"
+			volta = &fakes.VersionSource{}
+			volta.NameCall.Returns.String = "volta"
+
+			registry = nodeengine.NewVersionSourceRegistry(nvmrc, packageJSON, volta)
+		})
+
+		it("enables every source when the setting is empty", func() {
+			sources, err := registry.Enabled("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal([]nodeengine.VersionSource{nvmrc, packageJSON, volta}))
+		})
+
+		it("enables only the listed sources, in registry order", func() {
+			sources, err := registry.Enabled("volta, .nvmrc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal([]nodeengine.VersionSource{nvmrc, volta}))
+		})
+
+		it("disables the sources prefixed with a dash", func() {
+			sources, err := registry.Enabled("-package.json")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal([]nodeengine.VersionSource{nvmrc, volta}))
+		})
+
+		context("failure cases", func() {
+			context("when the setting names an unknown source", func() {
+				it("returns an error listing the known sources", func() {
+					_, err := registry.Enabled(".nvmrc,.python-version")
+					Expect(err).To(MatchError(`unknown version source ".python-version" in BP_NODE_VERSION_SOURCES, expected one of: .nvmrc, package.json, volta`))
+				})
+			})
+
+			context("when the setting both enables and disables sources", func() {
+				it("returns an error", func() {
+					_, err := registry.Enabled(".nvmrc,-volta")
+					Expect(err).To(MatchError(`BP_NODE_VERSION_SOURCES must either list the sources to enable or the sources to disable, not both: ".nvmrc,-volta"`))
+				})
+			})
+		})
+	})
+
+	context("FileVersionSource", func() {
+		var (
+			parser *fakes.VersionParser
+			source nodeengine.FileVersionSource
+		)
+
+		it.Before(func() {
+			parser = &fakes.VersionParser{}
+			source = nodeengine.NewFileVersionSource(".nvmrc", ".nvmrc", parser)
+		})
+
+		it("parses the file in the project directory", func() {
+			parser.ParseVersionCall.Returns.Result = nodeengine.VersionResult{Raw: "v20", Constraint: "20", Path: "/working-dir/project/.nvmrc", Line: 1}
+
+			result, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(result).To(Equal(nodeengine.VersionResult{Raw: "v20", Constraint: "20", Path: "/working-dir/project/.nvmrc", Line: 1}))
+
+			Expect(source.Name()).To(Equal(".nvmrc"))
+			Expect(parser.ParseVersionCall.Receives.Path).To(Equal("/working-dir/project/.nvmrc"))
+		})
+
+		it("does not find a version when the file does not constrain it", func() {
+			_, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		context("when the file is a symlink to a file outside of the working directory", func() {
+			var workingDir string
+
+			it.Before(func() {
+				workingDir = t.TempDir()
+				outside := filepath.Join(t.TempDir(), "secret")
+				Expect(os.WriteFile(outside, []byte("20"), 0600)).To(Succeed())
+				Expect(os.Symlink(outside, filepath.Join(workingDir, ".nvmrc"))).To(Succeed())
+			})
+
+			it("fails detection without reading the file", func() {
+				_, _, err := source.Find(workingDir, workingDir)
+				Expect(err).To(MatchError(fmt.Sprintf("expected .nvmrc version file [%s] to be inside the application directory [%s]", filepath.Join(workingDir, ".nvmrc"), workingDir)))
+				Expect(parser.ParseVersionCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the parser fails", func() {
+			it("returns an error", func() {
+				parser.ParseVersionCall.Returns.Err = errors.New("failed to parse")
+
+				_, _, err := source.Find("/working-dir", "/working-dir/project")
+				Expect(err).To(MatchError("failed to parse"))
+			})
+		})
+	})
+
+	context("EnvironmentVersionSource", func() {
+		var (
+			resolver *fakes.VersionResolver
+			source   nodeengine.EnvironmentVersionSource
+		)
+
+		it.Before(func() {
+			resolver = &fakes.VersionResolver{}
+			source = nodeengine.NewEnvironmentVersionSource("BP_NODE_VERSION", resolver)
+		})
+
+		it("resolves the value of the variable", func() {
+			t.Setenv("BP_NODE_VERSION", "lts")
+			resolver.ResolveVersionCall.Returns.Constraint = "22.*"
+			resolver.ResolveVersionCall.Returns.Ok = true
+
+			result, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(result).To(Equal(nodeengine.VersionResult{Raw: "lts", Constraint: "22.*"}))
+
+			Expect(source.Name()).To(Equal("BP_NODE_VERSION"))
+			Expect(resolver.ResolveVersionCall.Receives.Version).To(Equal("lts"))
+		})
+
+		it("returns an error when the value is not a valid version constraint", func() {
+			t.Setenv("BP_NODE_VERSION", "not-a-version")
+
+			_, _, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).To(MatchError(`invalid version constraint specified in BP_NODE_VERSION: "not-a-version"`))
+		})
+
+		it("does not find a version when the variable is unset", func() {
+			_, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(resolver.ResolveVersionCall.CallCount).To(Equal(0))
+		})
+	})
+
+	context("VoltaVersionSource", func() {
+		var (
+			parser *fakes.ToolchainParser
+			source nodeengine.VoltaVersionSource
+		)
+
+		it.Before(func() {
+			parser = &fakes.ToolchainParser{}
+			source = nodeengine.NewVoltaVersionSource(parser)
+		})
+
+		it("returns the pinned toolchain", func() {
+			parser.ParseToolchainCall.Returns.Toolchain = nodeengine.Toolchain{Node: "20.11.1", Npm: "10.2.4", Yarn: "1.22.19"}
+
+			result, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(result).To(Equal(nodeengine.VersionResult{
+				Raw:        "20.11.1",
+				Constraint: "20.11.1",
+				Path:       "/working-dir/project/package.json",
+				Npm:        "10.2.4",
+				Yarn:       "1.22.19",
+			}))
+
+			Expect(parser.ParseToolchainCall.Receives.WorkingDir).To(Equal("/working-dir"))
+			Expect(parser.ParseToolchainCall.Receives.Path).To(Equal("/working-dir/project/package.json"))
+		})
+
+		it("does not find a version when nothing is pinned", func() {
+			_, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		it("returns the package manager pins without a version when node is not pinned", func() {
+			parser.ParseToolchainCall.Returns.Toolchain = nodeengine.Toolchain{Yarn: "1.22.19"}
+
+			result, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(result).To(Equal(nodeengine.VersionResult{
+				Path: "/working-dir/project/package.json",
+				Yarn: "1.22.19",
+			}))
+		})
+	})
+
+	context("DevEnginesVersionSource", func() {
+		var (
+			parser *fakes.RuntimeParser
+			source nodeengine.DevEnginesVersionSource
+		)
+
+		it.Before(func() {
+			parser = &fakes.RuntimeParser{}
+			source = nodeengine.NewDevEnginesVersionSource(parser)
+		})
+
+		it("returns the runtime version along with its onFail behavior", func() {
+			parser.ParseRuntimeCall.Returns.Runtime = nodeengine.Runtime{Version: "^22", OnFail: "warn"}
+
+			result, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(result).To(Equal(nodeengine.VersionResult{
+				Raw:        "^22",
+				Constraint: "^22",
+				Path:       "/working-dir/project/package.json",
+				OnFail:     "warn",
+			}))
+		})
+
+		it("does not find a version when no runtime is given", func() {
+			_, found, err := source.Find("/working-dir", "/working-dir/project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+}
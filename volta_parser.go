@@ -0,0 +1,112 @@
+package nodeengine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Toolchain describes the versions of Node.js and its package managers that
+// a project has pinned.
+type Toolchain struct {
+	Node string
+	Npm  string
+	Yarn string
+}
+
+type VoltaParser struct{}
+
+func NewVoltaParser() VoltaParser {
+	return VoltaParser{}
+}
+
+// ParseToolchain reads the volta configuration of the package.json at the
+// given path. Configuration inherited through volta.extends is followed as
+// long as the extended files, with their symlinks resolved, are within the
+// working directory, with the values of the extending file taking
+// precedence.
+func (p VoltaParser) ParseToolchain(workingDir, path string) (Toolchain, error) {
+	var toolchain Toolchain
+
+	visited := map[string]bool{}
+	for {
+		within, err := resolvesWithin(workingDir, path)
+		if err != nil {
+			return Toolchain{}, err
+		}
+
+		if !within {
+			return Toolchain{}, fmt.Errorf("volta configuration extends %s, which is outside of the working directory", path)
+		}
+
+		if visited[path] {
+			return Toolchain{}, fmt.Errorf("volta configuration in %s extends itself", path)
+		}
+		visited[path] = true
+
+		pkg, err := parsePackageJSON(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && len(visited) == 1 {
+				return Toolchain{}, nil
+			}
+			return Toolchain{}, err
+		}
+
+		if toolchain.Node == "" {
+			toolchain.Node = pkg.Volta.Node
+		}
+
+		if toolchain.Npm == "" {
+			toolchain.Npm = pkg.Volta.Npm
+		}
+
+		if toolchain.Yarn == "" {
+			toolchain.Yarn = pkg.Volta.Yarn
+		}
+
+		if pkg.Volta.Extends == "" {
+			break
+		}
+
+		extends := pkg.Volta.Extends
+		if !filepath.IsAbs(extends) {
+			extends = filepath.Join(filepath.Dir(path), extends)
+		}
+		path = filepath.Clean(extends)
+	}
+
+	if toolchain.Node != "" {
+		node, err := p.validateVoltaNode(toolchain.Node)
+		if err != nil {
+			return Toolchain{}, err
+		}
+		toolchain.Node = node
+	}
+
+	return toolchain, nil
+}
+
+func (p VoltaParser) validateVoltaNode(content string) (string, error) {
+	content = strings.TrimPrefix(strings.TrimSpace(strings.ToLower(content)), "v")
+
+	if _, err := semver.NewConstraint(content); err != nil {
+		return "", fmt.Errorf("invalid version constraint specified in package.json volta.node: %q", content)
+	}
+
+	return content, nil
+}
+
+// isWithin reports whether the given path is the directory dir or one of its
+// descendants.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
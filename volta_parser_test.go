@@ -0,0 +1,173 @@
+package nodeengine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodeengine "github.com/paketo-buildpacks/node-engine/v5"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVoltaParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		path       string
+		parser     nodeengine.VoltaParser
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+		Expect(os.MkdirAll(filepath.Join(workingDir, "packages", "app"), os.ModePerm)).To(Succeed())
+		path = filepath.Join(workingDir, "packages", "app", "package.json")
+
+		parser = nodeengine.NewVoltaParser()
+	})
+
+	it("returns the pinned toolchain", func() {
+		Expect(os.WriteFile(path, []byte(`{
+			"volta": {"node": "v20.11.1", "npm": "10.2.4", "yarn": "1.22.19"}
+		}`), 0644)).To(Succeed())
+
+		toolchain, err := parser.ParseToolchain(workingDir, path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(toolchain).To(Equal(nodeengine.Toolchain{
+			Node: "20.11.1",
+			Npm:  "10.2.4",
+			Yarn: "1.22.19",
+		}))
+	})
+
+	it("returns a pinned release candidate", func() {
+		Expect(os.WriteFile(path, []byte(`{
+			"volta": {"node": "v23.0.0-rc.1"}
+		}`), 0644)).To(Succeed())
+
+		toolchain, err := parser.ParseToolchain(workingDir, path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(toolchain).To(Equal(nodeengine.Toolchain{
+			Node: "23.0.0-rc.1",
+		}))
+	})
+
+	context("when the volta configuration extends another file", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte(`{
+				"volta": {"npm": "10.5.0", "extends": "../base.json"}
+			}`), 0644)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "packages", "base.json"), []byte(`{
+				"volta": {"node": "20.11.1", "npm": "10.2.4", "extends": "../package.json"}
+			}`), 0644)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+				"volta": {"node": "18.19.0", "yarn": "1.22.19"}
+			}`), 0644)).To(Succeed())
+		})
+
+		it("merges the chain with the extending file taking precedence", func() {
+			toolchain, err := parser.ParseToolchain(workingDir, path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolchain).To(Equal(nodeengine.Toolchain{
+				Node: "20.11.1",
+				Npm:  "10.5.0",
+				Yarn: "1.22.19",
+			}))
+		})
+	})
+
+	context("when the package.json has no volta configuration", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(path, []byte(`{"engines": {"node": "20"}}`), 0644)).To(Succeed())
+		})
+
+		it("returns an empty toolchain", func() {
+			toolchain, err := parser.ParseToolchain(workingDir, path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolchain).To(Equal(nodeengine.Toolchain{}))
+		})
+	})
+
+	context("when the package.json file does not exist", func() {
+		it("returns an empty toolchain", func() {
+			toolchain, err := parser.ParseToolchain(workingDir, path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolchain).To(Equal(nodeengine.Toolchain{}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the volta configuration extends a file outside of the working directory", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`{
+					"volta": {"extends": "../../../package.json"}
+				}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseToolchain(workingDir, path)
+				Expect(err).To(MatchError(ContainSubstring("which is outside of the working directory")))
+			})
+		})
+
+		context("when the volta configuration extends a symlink to a file outside of the working directory", func() {
+			it.Before(func() {
+				outside := filepath.Join(t.TempDir(), "package.json")
+				Expect(os.WriteFile(outside, []byte(`{"volta": {"node": "20"}}`), 0644)).To(Succeed())
+				Expect(os.Symlink(outside, filepath.Join(workingDir, "shared.json"))).To(Succeed())
+
+				Expect(os.WriteFile(path, []byte(`{
+					"volta": {"extends": "../../shared.json"}
+				}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseToolchain(workingDir, path)
+				Expect(err).To(MatchError(ContainSubstring("which is outside of the working directory")))
+			})
+		})
+
+		context("when the volta configuration extends a file that does not exist", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`{
+					"volta": {"extends": "../missing.json"}
+				}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseToolchain(workingDir, path)
+				Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+			})
+		})
+
+		context("when the volta configuration extends itself", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`{
+					"volta": {"extends": "./package.json"}
+				}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseToolchain(workingDir, path)
+				Expect(err).To(MatchError(ContainSubstring("extends itself")))
+			})
+		})
+
+		context("when volta.node is malformed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(path, []byte(`{
+					"volta": {"node": "not-a-version"}
+				}`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := parser.ParseToolchain(workingDir, path)
+				Expect(err).To(MatchError("invalid version constraint specified in package.json volta.node: \"not-a-version\""))
+			})
+		})
+	})
+}
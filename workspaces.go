@@ -0,0 +1,353 @@
+package nodeengine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"gopkg.in/yaml.v3"
+)
+
+// RootWorkspace names the project directory itself among its workspaces.
+const RootWorkspace = "."
+
+// findWorkspaces returns the workspaces of the project at projectPath as
+// paths relative to it, starting with the project itself. The workspaces are
+// configured through BP_NODE_WORKSPACES, which is either a comma-separated
+// list of directories or "auto" to discover the packages matched by the
+// workspaces field of package.json and the packages field of
+// pnpm-workspace.yaml. Workspaces have to be inside of workingDir.
+func findWorkspaces(workingDir, projectPath string) ([]string, error) {
+	setting := strings.TrimSpace(os.Getenv("BP_NODE_WORKSPACES"))
+	if setting == "" {
+		return []string{RootWorkspace}, nil
+	}
+
+	var (
+		workspaces []string
+		err        error
+	)
+
+	if setting == "auto" {
+		workspaces, err = discoverWorkspaces(workingDir, projectPath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, workspace := range strings.Split(setting, ",") {
+			workspace = filepath.Clean(strings.TrimSpace(workspace))
+
+			info, err := os.Stat(filepath.Join(projectPath, workspace))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+
+			if info == nil || !info.IsDir() {
+				return nil, packit.Fail.WithMessage("expected value derived from BP_NODE_WORKSPACES [%s] to be an existing directory", filepath.Join(projectPath, workspace))
+			}
+
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	result := []string{RootWorkspace}
+	seen := map[string]bool{RootWorkspace: true}
+	for _, workspace := range workspaces {
+		within, err := resolvesWithin(workingDir, filepath.Join(projectPath, workspace))
+		if err != nil {
+			return nil, err
+		}
+
+		if !within {
+			return nil, packit.Fail.WithMessage("expected workspace [%s] to be inside the application directory [%s]", filepath.Join(projectPath, workspace), workingDir)
+		}
+
+		if !seen[workspace] {
+			seen[workspace] = true
+			result = append(result, workspace)
+		}
+	}
+
+	return result, nil
+}
+
+// discoverWorkspaces expands the workspace patterns of the project into the
+// package directories they match, sorted by path. Patterns prefixed with "!"
+// exclude the packages they match. Like in npm, yarn and pnpm, a "**" segment
+// matches any number of directories, and node_modules directories are not
+// searched.
+func discoverWorkspaces(workingDir, projectPath string) ([]string, error) {
+	patterns, err := workspacePatterns(workingDir, projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var includes, excludes []string
+	for _, pattern := range patterns {
+		exclude, excluded := strings.CutPrefix(pattern, "!")
+		if excluded {
+			pattern = exclude
+		}
+
+		pattern = filepath.ToSlash(filepath.Clean(pattern))
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+		}
+
+		if excluded {
+			excludes = append(excludes, pattern)
+		} else {
+			includes = append(includes, pattern)
+		}
+	}
+
+	if len(includes) == 0 {
+		return nil, nil
+	}
+
+	var workspaces []string
+	err = filepath.WalkDir(projectPath, func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if dir == projectPath {
+			return nil
+		}
+
+		if entry.IsDir() && entry.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+
+		// Symlinked packages are not descended into, but can be matched
+		// themselves, as findWorkspaces checks where they resolve to.
+		if entry.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(dir)
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+		} else if !entry.IsDir() {
+			return nil
+		}
+
+		workspace, err := filepath.Rel(projectPath, dir)
+		if err != nil {
+			return err
+		}
+		workspace = filepath.ToSlash(workspace)
+
+		if !matchesAny(includes, workspace) || matchesAny(excludes, workspace) {
+			return nil
+		}
+
+		info, err := os.Stat(filepath.Join(dir, PackageJSONSource))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if info != nil && info.Mode().IsRegular() {
+			workspaces = append(workspaces, filepath.FromSlash(workspace))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover workspaces: %w", err)
+	}
+
+	sort.Strings(workspaces)
+
+	return workspaces, nil
+}
+
+// matchesAny reports whether the slash-separated workspace path matches any of
+// the given workspace patterns.
+func matchesAny(patterns []string, workspace string) bool {
+	for _, pattern := range patterns {
+		if matchSegments(strings.Split(pattern, "/"), strings.Split(workspace, "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchSegments matches the segments of a path against those of a pattern. A
+// "**" segment matches any number of path segments, including none, and the
+// other segments are matched with path.Match.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// workspacePatterns reads the workspace patterns of the project from the
+// workspaces field of package.json, given either as a list or as a yarn
+// packages table, and from the packages field of pnpm-workspace.yaml. Both
+// files have to be inside of workingDir.
+func workspacePatterns(workingDir, projectPath string) ([]string, error) {
+	var patterns []string
+
+	path := filepath.Join(projectPath, PackageJSONSource)
+	err := checkVersionFile("workspaces", workingDir, path)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := parsePackageJSON(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(pkg.Workspaces) > 0 {
+		var workspaces []string
+		if err := json.Unmarshal(pkg.Workspaces, &workspaces); err != nil {
+			var yarnWorkspaces struct {
+				Packages []string `json:"packages"`
+			}
+
+			if err := json.Unmarshal(pkg.Workspaces, &yarnWorkspaces); err != nil {
+				return nil, fmt.Errorf("failed to parse package.json workspaces: %w", err)
+			}
+
+			workspaces = yarnWorkspaces.Packages
+		}
+
+		patterns = append(patterns, workspaces...)
+	}
+
+	path = filepath.Join(projectPath, "pnpm-workspace.yaml")
+	err = checkVersionFile("workspaces", workingDir, path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(content) > 0 {
+		var pnpmWorkspace struct {
+			Packages []string `yaml:"packages"`
+		}
+
+		if err := yaml.Unmarshal(content, &pnpmWorkspace); err != nil {
+			return nil, fmt.Errorf("failed to parse pnpm-workspace.yaml: %w", err)
+		}
+
+		patterns = append(patterns, pnpmWorkspace.Packages...)
+	}
+
+	return patterns, nil
+}
+
+// planWorkspaces returns the workspaces named by the given plan entries, in
+// the order they first appear.
+func planWorkspaces(entries []packit.BuildpackPlanEntry) []string {
+	var workspaces []string
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		workspace, _ := entry.Metadata["workspace"].(string)
+		if workspace != "" && !seen[workspace] {
+			seen[workspace] = true
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	return workspaces
+}
+
+// intersectWorkspaces selects the highest priority version requirement of
+// each workspace and returns a plan entry requesting the newest dependency
+// that satisfies all of them. When no dependency does, the returned
+// IntersectionError reports the requirement of every workspace.
+func intersectWorkspaces(entries []packit.BuildpackPlanEntry, dependencies []postal.Dependency) (packit.BuildpackPlanEntry, error) {
+	var selected []packit.BuildpackPlanEntry
+	for _, workspace := range planWorkspaces(entries) {
+		var candidate packit.BuildpackPlanEntry
+		for _, entry := range entries {
+			if w, _ := entry.Metadata["workspace"].(string); w != workspace {
+				continue
+			}
+
+			if candidate.Name == "" || hasHigherPriority(entry, candidate) {
+				candidate = entry
+			}
+		}
+
+		selected = append(selected, candidate)
+	}
+
+	return intersectVersions(selected, dependencies)
+}
+
+// hasHigherPriority reports whether the version source of entry ranks above
+// that of other. Sources that are not ranked come last.
+func hasHigherPriority(entry, other packit.BuildpackPlanEntry) bool {
+	source, _ := entry.Metadata["version-source"].(string)
+	otherSource, _ := other.Metadata["version-source"].(string)
+
+	priority, otherPriority := sourcePriority(source), sourcePriority(otherSource)
+	if priority == 0 {
+		return false
+	}
+
+	return otherPriority == 0 || priority < otherPriority
+}
+
+// sourcePriority returns the position of the given version source in
+// versionSourcePriorities, starting at 1 for the highest priority source, or
+// 0 when the source is not ranked.
+func sourcePriority(source string) int {
+	for i, priority := range versionSourcePriorities {
+		if priority == source {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// planEntryLabel names the version source of a plan entry, qualified by the
+// workspace it was found in when there is one.
+func planEntryLabel(entry packit.BuildpackPlanEntry) string {
+	source, ok := entry.Metadata["version-source"].(string)
+	if !ok {
+		source = "<unknown>"
+	}
+
+	if workspace, _ := entry.Metadata["workspace"].(string); workspace != "" {
+		return fmt.Sprintf("%s (%s)", source, workspace)
+	}
+
+	return source
+}